@@ -0,0 +1,311 @@
+/*
+Background subtraction with the FGD, Gaussian mixture and codebook models
+from cvaux.
+*/
+package opencv
+
+// #include <opencv/cv.h>
+// #include <opencv/cvaux.h>
+import "C"
+import "errors"
+
+// BackgroundModel learns the background of a video from a static camera
+// and separates moving objects from it.
+type BackgroundModel interface {
+  // Update learns from the next frame and returns its foreground mask, an
+  // 8-bit image that is non-zero where the frame differs from the
+  // background. The mask is a copy, release it when done.
+  Update(frame * Image) (foreground * Image, err error)
+  // Background returns a copy of the current background image of the
+  // model, or nil if the model does not keep one. Release it when done.
+  Background() * Image
+  // Release releases the model.
+  Release()
+}
+
+var _ BackgroundModel = (* FGDModel)(nil)
+var _ BackgroundModel = (* MOGModel)(nil)
+var _ BackgroundModel = (* CodebookModel)(nil)
+
+// FGDParams are the parameters of the foreground object detection model
+// of Li, Huang, Gu and Tian.
+type FGDParams struct {
+  // Lc, N1c and N2c are the quantization levels and vector counts of the
+  // color features of stationary pixels.
+  Lc, N1c, N2c     int
+  // Lcc, N1cc and N2cc are the same for the color co-occurrence features
+  // of moving pixels.
+  Lcc, N1cc, N2cc  int
+  // If ObjWithoutHoles is true, holes in the foreground objects are filled.
+  ObjWithoutHoles  bool
+  // If PerformMorphing is true, the foreground mask is cleaned with an
+  // opening and closing.
+  PerformMorphing  bool
+  // Alpha1, Alpha2 and Alpha3 are the learning rates of the reference
+  // background, of the feature statistics and of the feature appearance.
+  Alpha1, Alpha2, Alpha3 float32
+  // Delta affects color quantization, T decides whether a feature belongs
+  // to the background.
+  Delta, T         float32
+  // MinArea discards foreground blobs smaller than this.
+  MinArea          float32
+}
+
+// DefaultFGDParams returns the default BGFG_FGD_* parameters.
+func DefaultFGDParams() FGDParams {
+  return FGDParams{
+    Lc: BGFG_FGD_LC, N1c: BGFG_FGD_N1C, N2c: BGFG_FGD_N2C,
+    Lcc: BGFG_FGD_LCC, N1cc: BGFG_FGD_N1CC, N2cc: BGFG_FGD_N2CC,
+    ObjWithoutHoles: true,
+    PerformMorphing: true,
+    Alpha1: BGFG_FGD_ALPHA_1, Alpha2: BGFG_FGD_ALPHA_2, Alpha3: BGFG_FGD_ALPHA_3,
+    Delta: BGFG_FGD_DELTA, T: BGFG_FGD_T,
+    MinArea: BGFG_FGD_MINAREA,
+  }
+}
+
+// cparams converts the parameters to OpenCV CvFGDStatModelParams.
+func (self FGDParams) cparams() C.CvFGDStatModelParams {
+  var cparams C.CvFGDStatModelParams
+  cparams.Lc, cparams.N1c, cparams.N2c       = C.int(self.Lc), C.int(self.N1c), C.int(self.N2c)
+  cparams.Lcc, cparams.N1cc, cparams.N2cc    = C.int(self.Lcc), C.int(self.N1cc), C.int(self.N2cc)
+  cparams.is_obj_without_holes               = cbool(self.ObjWithoutHoles)
+  cparams.perform_morphing                   = cbool(self.PerformMorphing)
+  cparams.alpha1, cparams.alpha2, cparams.alpha3 = C.float(self.Alpha1), C.float(self.Alpha2), C.float(self.Alpha3)
+  cparams.delta, cparams.T                   = C.float(self.Delta), C.float(self.T)
+  cparams.minArea                            = C.float(self.MinArea)
+  return cparams
+}
+
+// MOGParams are the parameters of the Gaussian mixture model of
+// KaewTraKulPong and Bowden.
+type MOGParams struct {
+  // WindowSize is the learning window, 1 / WindowSize the learning rate.
+  WindowSize          int
+  // NGaussians is the number of Gaussians per pixel.
+  NGaussians          int
+  // BackgroundThreshold is the portion of the weights that make up the
+  // background.
+  BackgroundThreshold float64
+  // StdThreshold is the distance, in standard deviations, within which a
+  // pixel matches a Gaussian.
+  StdThreshold        float64
+  // MinArea discards foreground blobs smaller than this.
+  MinArea             float64
+  // WeightInit and VarianceInit initialize new Gaussians.
+  WeightInit          float64
+  VarianceInit        float64
+}
+
+// DefaultMOGParams returns the default BGFG_MOG_* parameters.
+func DefaultMOGParams() MOGParams {
+  return MOGParams{
+    WindowSize:          BGFG_MOG_WINDOW_SIZE,
+    NGaussians:          BGFG_MOG_NGAUSSIANS,
+    BackgroundThreshold: BGFG_MOG_BACKGROUND_THRESHOLD,
+    StdThreshold:        BGFG_MOG_STD_THRESHOLD,
+    MinArea:             BGFG_MOG_MINAREA,
+    WeightInit:          BGFG_MOG_WEIGHT_INIT,
+    VarianceInit:        BGFG_MOG_SIGMA_INIT * BGFG_MOG_SIGMA_INIT,
+  }
+}
+
+// cparams converts the parameters to OpenCV CvGaussBGStatModelParams.
+func (self MOGParams) cparams() C.CvGaussBGStatModelParams {
+  var cparams C.CvGaussBGStatModelParams
+  cparams.win_size      = C.int(self.WindowSize)
+  cparams.n_gauss       = C.int(self.NGaussians)
+  cparams.bg_threshold  = C.double(self.BackgroundThreshold)
+  cparams.std_threshold = C.double(self.StdThreshold)
+  cparams.minArea       = C.double(self.MinArea)
+  cparams.weight_init   = C.double(self.WeightInit)
+  cparams.variance_init = C.double(self.VarianceInit)
+  return cparams
+}
+
+// statModel implements BackgroundModel for the OpenCV CvBGStatModel based
+// models.
+type statModel struct {
+  // LearningRate is the rate at which the model adapts, or -1 to use the
+  // rate the model was created with.
+  LearningRate float64
+  cmodel       * C.CvBGStatModel
+}
+
+// cloneImage copies an image that belongs to a model, so the caller can
+// release the copy. Returns nil if there is no image or it can't be copied.
+func cloneImage(cimage * C.IplImage) * Image {
+  if cimage == nil { return nil }
+  clone := WrapImage(C.cvCloneImage(cimage))
+  if lastError() != nil {
+    clone.Release()
+    return nil
+  }
+  return clone
+}
+
+// Update learns from the next frame and returns a copy of its foreground
+// mask.
+func (self * statModel) Update(frame * Image) (* Image, error) {
+  if self.cmodel == nil { return nil, errors.New("opencv: background model was released") }
+  if frame == nil { return nil, errors.New("opencv: background model needs a frame") }
+  C.cvUpdateBGStatModel(frame.cimage, self.cmodel, C.double(self.LearningRate))
+  if err := lastError(); err != nil { return nil, err }
+  foreground := cloneImage(self.cmodel.foreground)
+  if foreground == nil { return nil, errors.New("opencv: could not copy foreground mask") }
+  return foreground, nil
+}
+
+// Background returns a copy of the current background image of the model.
+func (self * statModel) Background() * Image {
+  if self.cmodel == nil { return nil }
+  return cloneImage(self.cmodel.background)
+}
+
+// Release releases the model.
+func (self * statModel) Release() {
+  if self.cmodel != nil {
+    C.cvReleaseBGStatModel(&self.cmodel)
+  }
+  self.cmodel = nil
+}
+
+// FGDModel is the foreground object detection background model.
+type FGDModel struct {
+  statModel
+}
+
+// NewFGDModel creates an FGD model initialized from the first 8-bit,
+// 3-channel frame of a video.
+func NewFGDModel(first * Image, params FGDParams) (* FGDModel, error) {
+  if first == nil { return nil, errors.New("opencv: background model needs a first frame") }
+  cparams := params.cparams()
+  cmodel  := C.cvCreateFGDStatModel(first.cimage, &cparams)
+  if err := lastError(); err != nil { return nil, err }
+  if cmodel == nil { return nil, errors.New("opencv: could not create FGD model") }
+  return &FGDModel{statModel{-1, cmodel}}, nil
+}
+
+// MOGModel is the Gaussian mixture background model.
+type MOGModel struct {
+  statModel
+}
+
+// NewMOGModel creates a Gaussian mixture model initialized from the first
+// 8-bit frame of a video.
+func NewMOGModel(first * Image, params MOGParams) (* MOGModel, error) {
+  if first == nil { return nil, errors.New("opencv: background model needs a first frame") }
+  if params.NGaussians < 1 || params.NGaussians > BGFG_MOG_MAX_NGAUSSIANS {
+    return nil, errors.New("opencv: invalid number of Gaussians")
+  }
+  cparams := params.cparams()
+  cmodel  := C.cvCreateGaussianBGModel(first.cimage, &cparams)
+  if err := lastError(); err != nil { return nil, err }
+  if cmodel == nil { return nil, errors.New("opencv: could not create Gaussian mixture model") }
+  return &MOGModel{statModel{-1, cmodel}}, nil
+}
+
+// CodebookParams are the parameters of the codebook background model.
+type CodebookParams struct {
+  // Bounds are the per-channel bounds used to learn new codebook entries.
+  Bounds         [3]int
+  // ModMin and ModMax widen the codebook entries per channel when
+  // detecting the foreground.
+  ModMin, ModMax [3]int
+  // LearnFrames is the number of frames that are learned from before the
+  // foreground is detected. Until then, Update returns an empty mask.
+  LearnFrames    int
+  // StaleThreshold removes entries not seen for this many frames once
+  // learning is done.
+  StaleThreshold int
+  // If Cleanup is true, the foreground mask is cleaned by replacing every
+  // blob with its approximated polygon, and removing small blobs.
+  Cleanup        bool
+}
+
+// DefaultCodebookParams returns the default codebook parameters of OpenCV,
+// learning from the first 30 frames.
+func DefaultCodebookParams() CodebookParams {
+  return CodebookParams{
+    Bounds:         [3]int{10, 10, 10},
+    ModMin:         [3]int{3, 3, 3},
+    ModMax:         [3]int{10, 10, 10},
+    LearnFrames:    30,
+    StaleThreshold: 15,
+    Cleanup:        true,
+  }
+}
+
+// CodebookModel is the codebook background model of Kim, Chalidabhongse,
+// Harwood and Davis. It works best on frames in the YCrCb color space. The
+// parameters are applied on every call to Update.
+type CodebookModel struct {
+  Params     CodebookParams
+  cmodel     * C.CvBGCodeBookModel
+  foreground * Image
+  frames     int
+}
+
+// NewCodebookModel creates an empty codebook model.
+func NewCodebookModel(params CodebookParams) (* CodebookModel, error) {
+  cmodel := C.cvCreateBGCodeBookModel()
+  if err := lastError(); err != nil { return nil, err }
+  if cmodel == nil { return nil, errors.New("opencv: could not create codebook model") }
+  return &CodebookModel{Params: params, cmodel: cmodel}, nil
+}
+
+// Update learns from the next 8-bit, 3-channel frame and returns a copy of
+// its foreground mask.
+func (self * CodebookModel) Update(frame * Image) (* Image, error) {
+  if self.cmodel == nil { return nil, errors.New("opencv: background model was released") }
+  if frame == nil { return nil, errors.New("opencv: background model needs a frame") }
+  if frame.Depth() != IPL_DEPTH_8U || frame.Channels() != 3 {
+    return nil, errors.New("opencv: codebook model needs 8-bit, 3-channel frames")
+  }
+  if self.foreground == nil {
+    self.foreground = CreateImage(frame.Width(), frame.Height(), IPL_DEPTH_8U, 1)
+    if self.foreground == nil { return nil, errors.New("opencv: could not allocate foreground mask") }
+  } else if self.foreground.Width() != frame.Width() || self.foreground.Height() != frame.Height() {
+    return nil, errors.New("opencv: frame size does not match the background model")
+  }
+  for i := 0; i < 3; i++ {
+    self.cmodel.cbBounds[i] = C.uchar(self.Params.Bounds[i])
+    self.cmodel.modMin[i]   = C.uchar(self.Params.ModMin[i])
+    self.cmodel.modMax[i]   = C.uchar(self.Params.ModMax[i])
+  }
+  // An empty rectangle means the whole frame.
+  croi := C.cvRect(0, 0, 0, 0)
+  if self.frames < self.Params.LearnFrames {
+    C.cvBGCodeBookUpdate(self.cmodel, frame.arr(), croi, nil)
+    self.frames++
+    if self.frames == self.Params.LearnFrames {
+      C.cvBGCodeBookClearStale(self.cmodel, C.int(self.Params.StaleThreshold), croi, nil)
+    }
+    C.cvSetZero(self.foreground.arr())
+  } else {
+    C.cvBGCodeBookDiff(self.cmodel, frame.arr(), self.foreground.arr(), croi)
+    if self.Params.Cleanup {
+      C.cvSegmentFGMask(self.foreground.arr(), 1, 4, nil, C.cvPoint(0, 0))
+    }
+  }
+  if err := lastError(); err != nil { return nil, err }
+  foreground := cloneImage(self.foreground.cimage)
+  if foreground == nil { return nil, errors.New("opencv: could not copy foreground mask") }
+  return foreground, nil
+}
+
+// Background returns nil, codebook models do not keep a background image.
+func (self * CodebookModel) Background() * Image {
+  return nil
+}
+
+// Release releases the model and its foreground mask.
+func (self * CodebookModel) Release() {
+  if self.cmodel != nil {
+    C.cvReleaseBGCodeBookModel(&self.cmodel)
+  }
+  self.cmodel = nil
+  self.foreground.Release()
+  self.foreground = nil
+  self.frames     = 0
+}
@@ -0,0 +1,65 @@
+/*
+Dense stereo correspondence with the Birchfield algorithm from cvaux.
+*/
+package opencv
+
+// #include <opencv/cv.h>
+// #include <opencv/cvaux.h>
+import "C"
+import "errors"
+
+// BirchfieldParams are the parameters of FindStereoCorrespondence.
+type BirchfieldParams struct {
+  // MaxDisparity is the largest disparity that is searched.
+  MaxDisparity     int
+  // Occlusion is the constant occlusion penalty.
+  Occlusion        float64
+  // MatchReward is the constant match reward.
+  MatchReward      float64
+  // HighlyReliable, ModeratelyReliable and SlightlyReliable define the
+  // regions of highly, moderately and slightly reliable disparities.
+  HighlyReliable     float64
+  ModeratelyReliable float64
+  SlightlyReliable   float64
+}
+
+// DefaultBirchfieldParams returns the documented default parameters
+// IDP_BIRCHFIELD_PARAM1 to IDP_BIRCHFIELD_PARAM5 for the given maximal
+// disparity.
+func DefaultBirchfieldParams(maxDisparity int) BirchfieldParams {
+  return BirchfieldParams{
+    MaxDisparity:       maxDisparity,
+    Occlusion:          IDP_BIRCHFIELD_PARAM1,
+    MatchReward:        IDP_BIRCHFIELD_PARAM2,
+    HighlyReliable:     IDP_BIRCHFIELD_PARAM3,
+    ModeratelyReliable: IDP_BIRCHFIELD_PARAM4,
+    SlightlyReliable:   IDP_BIRCHFIELD_PARAM5,
+  }
+}
+
+// FindStereoCorrespondence computes the disparities of the 8-bit single
+// channel rectified left and right images with the Birchfield algorithm
+// (DISPARITY_BIRCHFIELD). Returns an 8-bit image with the disparities of
+// the left image, from 0 to MaxDisparity. Release the result when done.
+func FindStereoCorrespondence(left, right * Image, params BirchfieldParams) (* Image, error) {
+  if left == nil || right == nil { return nil, errors.New("opencv: FindStereoCorrespondence needs a left and right image") }
+  if params.MaxDisparity < 1 { return nil, errors.New("opencv: MaxDisparity must be positive") }
+  disparity := CreateImage(left.Width(), left.Height(), IPL_DEPTH_8U, 1)
+  if disparity == nil { return nil, errors.New("opencv: could not allocate disparity image") }
+  C.cvFindStereoCorrespondence(left.arr(), right.arr(), DISPARITY_BIRCHFIELD, disparity.arr(),
+                               C.int(params.MaxDisparity), C.double(params.Occlusion),
+                               C.double(params.MatchReward), C.double(params.HighlyReliable),
+                               C.double(params.ModeratelyReliable), C.double(params.SlightlyReliable))
+  if err := lastError(); err != nil {
+    disparity.Release()
+    return nil, err
+  }
+  return disparity, nil
+}
+
+// DisplayDisparity scales a disparity image computed by
+// FindStereoCorrespondence to an 8-bit image, mapping the searched
+// disparities to 0 to 255. Release the result when done.
+func (self BirchfieldParams) DisplayDisparity(disparity * Image) (* Image, error) {
+  return ScaleDisparity(disparity, 255.0 / float64(self.MaxDisparity), 0)
+}
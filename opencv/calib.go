@@ -0,0 +1,192 @@
+/*
+Camera calibration with chessboard patterns.
+*/
+package opencv
+
+// #include <opencv/cv.h>
+import "C"
+import "errors"
+import "math"
+
+// CameraIntrinsics are the intrinsic parameters of a camera: the camera
+// matrix with the focal lengths and principal point, and the distortion
+// coefficients k1, k2, p1, p2, k3.
+type CameraIntrinsics struct {
+  Matrix     Mat3
+  Distortion [5]float64
+}
+
+// CalibrationResult is the result of CalibrateCamera2: the intrinsics of
+// the camera, the rotation vector and translation of the pattern in every
+// view, and the root mean square reprojection error in pixels.
+type CalibrationResult struct {
+  Intrinsics        CameraIntrinsics
+  Rotations         []Vec3
+  Translations      []Vec3
+  ReprojectionError float64
+}
+
+// cmats converts the intrinsics to a 3x3 camera matrix and 5x1 distortion
+// coefficients matrix. Release the results with releaseCMat.
+func (self CameraIntrinsics) cmats() (cmatrix, cdist * C.CvMat) {
+  cmatrix = self.Matrix.cmat()
+  data   := make([][]float64, len(self.Distortion))
+  for i, coeff := range self.Distortion { data[i] = []float64{coeff} }
+  cdist   = newCMat(len(self.Distortion), 1, CV_64F, data)
+  return cmatrix, cdist
+}
+
+// wrapIntrinsics copies a camera matrix and distortion coefficients to Go.
+func wrapIntrinsics(cmatrix, cdist * C.CvMat) CameraIntrinsics {
+  var intrinsics CameraIntrinsics
+  intrinsics.Matrix = wrapMat3(cmatrix)
+  for i, row := range cmatData(cdist) { intrinsics.Distortion[i] = row[0] }
+  return intrinsics
+}
+
+// ChessboardObjectPoints returns the 3D positions of the inner corners of a
+// chessboard with the given amount of inner corners per row and column, and
+// squares of the given size, in the order FindChessboardCorners finds them.
+// The chessboard lies in the Z = 0 plane.
+func ChessboardObjectPoints(pattern Size, squareSize float32) []Point3D32f {
+  points := make([]Point3D32f, 0, pattern.Width * pattern.Height)
+  for y := 0; y < pattern.Height; y++ {
+    for x := 0; x < pattern.Width; x++ {
+      points = append(points, Point3D32f{float32(x) * squareSize, float32(y) * squareSize, 0})
+    }
+  }
+  return points
+}
+
+// FindChessboardCorners finds the inner corners of a chessboard with the
+// given amount of inner corners per row and column in the 8-bit image.
+// flags is a combination of the CALIB_CB_* constants. found is true if all
+// corners were found and ordered, otherwise corners holds the corners that
+// were found. Use FindCornerSubPix to refine the corners.
+func FindChessboardCorners(image * Image, pattern Size, flags int) (corners []Point2D32f, found bool, err error) {
+  count := pattern.Width * pattern.Height
+  if image == nil || count < 1 {
+    return nil, false, errors.New("opencv: FindChessboardCorners needs an image and a pattern size")
+  }
+  ccorners := make([]C.CvPoint2D32f, count)
+  ccount   := C.int(0)
+  cfound   := C.cvFindChessboardCorners(image.arr(), pattern.csize(), &ccorners[0], &ccount, C.int(flags))
+  if err = lastError(); err != nil { return nil, false, err }
+  corners   = make([]Point2D32f, int(ccount))
+  for i := range corners { corners[i] = wrapPoint2D32f(ccorners[i]) }
+  return corners, cfound != 0, nil
+}
+
+// DrawChessboardCorners draws the chessboard corners on the image, connected
+// in order if found is true, or as red circles otherwise.
+func DrawChessboardCorners(image * Image, pattern Size, corners []Point2D32f, found bool) error {
+  if image == nil { return errors.New("opencv: DrawChessboardCorners needs an image") }
+  if len(corners) == 0 { return nil }
+  ccorners := make([]C.CvPoint2D32f, len(corners))
+  for i, corner := range corners { ccorners[i] = corner.cpoint() }
+  C.cvDrawChessboardCorners(image.arr(), pattern.csize(), &ccorners[0], C.int(len(corners)), cbool(found))
+  return lastError()
+}
+
+// calibPoints packs the object and image points of all views into the
+// matrices the OpenCV calibration functions expect. Release the results
+// with releaseCMat.
+func calibPoints(objectPoints [][]Point3D32f, imagePoints [][]Point2D32f) (cobject, cimage, ccounts * C.CvMat, err error) {
+  if len(objectPoints) == 0 || len(objectPoints) != len(imagePoints) {
+    return nil, nil, nil, errors.New("opencv: calibration needs object and image points for every view")
+  }
+  var objects, images [][]float64
+  counts := make([]float64, len(objectPoints))
+  for view := range objectPoints {
+    if len(objectPoints[view]) != len(imagePoints[view]) || len(objectPoints[view]) < 4 {
+      return nil, nil, nil, errors.New("opencv: every view needs the same amount of at least four object and image points")
+    }
+    counts[view] = float64(len(objectPoints[view]))
+    for i, point := range objectPoints[view] {
+      image  := imagePoints[view][i]
+      objects = append(objects, []float64{float64(point.X), float64(point.Y), float64(point.Z)})
+      images  = append(images, []float64{float64(image.X), float64(image.Y)})
+    }
+  }
+  cobject = newCMat(len(objects), 3, CV_32F, objects)
+  cimage  = newCMat(len(images), 2, CV_32F, images)
+  ccounts = newCMat(1, len(counts), CV_32S, [][]float64{counts})
+  return cobject, cimage, ccounts, nil
+}
+
+// reprojectionError returns the root mean square distance between the image
+// points and the object points projected with the calibration result.
+func (self * CalibrationResult) reprojectionError(objectPoints [][]Point3D32f, imagePoints [][]Point2D32f) (float64, error) {
+  sum   := 0.0
+  count := 0
+  for view := range objectPoints {
+    projected, _, err := ProjectPoints2(objectPoints[view], self.Rotations[view],
+                                        self.Translations[view], self.Intrinsics, false)
+    if err != nil { return 0, err }
+    for i, point := range projected {
+      dx  := float64(point.X - imagePoints[view][i].X)
+      dy  := float64(point.Y - imagePoints[view][i].Y)
+      sum += dx * dx + dy * dy
+      count++
+    }
+  }
+  if count == 0 { return 0, nil }
+  return math.Sqrt(sum / float64(count)), nil
+}
+
+// CalibrateCamera2 estimates the intrinsics of a camera from several views
+// of a calibration pattern. objectPoints holds the 3D points of the pattern
+// for every view and imagePoints where they were found in the images, which
+// are of the given size. flags is a combination of the CALIB_* constants;
+// with CALIB_USE_INTRINSIC_GUESS, or when fixing parameters, guess holds
+// the initial intrinsics, otherwise it may be nil.
+func CalibrateCamera2(objectPoints [][]Point3D32f, imagePoints [][]Point2D32f, imageSize Size,
+                      guess * CameraIntrinsics, flags int) (* CalibrationResult, error) {
+  cobject, cimage, ccounts, err := calibPoints(objectPoints, imagePoints)
+  if err != nil { return nil, err }
+  defer releaseCMat(cobject)
+  defer releaseCMat(cimage)
+  defer releaseCMat(ccounts)
+
+  var initial CameraIntrinsics
+  if guess != nil { initial = * guess }
+  cmatrix, cdist := initial.cmats()
+  defer releaseCMat(cmatrix)
+  defer releaseCMat(cdist)
+  views  := len(objectPoints)
+  crvecs := newCMat(views, 3, CV_64F, nil) ; defer releaseCMat(crvecs)
+  ctvecs := newCMat(views, 3, CV_64F, nil) ; defer releaseCMat(ctvecs)
+
+  C.cvCalibrateCamera2(cobject, cimage, ccounts, imageSize.csize(), cmatrix, cdist,
+                       crvecs, ctvecs, C.int(flags))
+  if err := lastError(); err != nil { return nil, err }
+
+  result := &CalibrationResult{Intrinsics: wrapIntrinsics(cmatrix, cdist)}
+  rvecs  := cmatData(crvecs)
+  tvecs  := cmatData(ctvecs)
+  result.Rotations    = make([]Vec3, views)
+  result.Translations = make([]Vec3, views)
+  for view := 0; view < views; view++ {
+    copy(result.Rotations[view][:], rvecs[view])
+    copy(result.Translations[view][:], tvecs[view])
+  }
+  result.ReprojectionError, err = result.reprojectionError(objectPoints, imagePoints)
+  if err != nil { return nil, err }
+  return result, nil
+}
+
+// InitIntrinsicParams2D estimates the camera matrix from several views of a
+// planar calibration pattern, see CalibrateCamera2. If aspectRatio is not
+// 0, the ratio of the focal lengths fx / fy is fixed to it.
+func InitIntrinsicParams2D(objectPoints [][]Point3D32f, imagePoints [][]Point2D32f, imageSize Size,
+                           aspectRatio float64) (Mat3, error) {
+  cobject, cimage, ccounts, err := calibPoints(objectPoints, imagePoints)
+  if err != nil { return Mat3{}, err }
+  defer releaseCMat(cobject)
+  defer releaseCMat(cimage)
+  defer releaseCMat(ccounts)
+  cmatrix := newCMat(3, 3, CV_64F, nil) ; defer releaseCMat(cmatrix)
+  C.cvInitIntrinsicParams2D(cobject, cimage, ccounts, imageSize.csize(), cmatrix, C.double(aspectRatio))
+  if err := lastError(); err != nil { return Mat3{}, err }
+  return wrapMat3(cmatrix), nil
+}
@@ -0,0 +1,153 @@
+/*
+MeanShift and CamShift object tracking on back projections.
+*/
+package opencv
+
+// #include <opencv/cv.h>
+import "C"
+import "errors"
+
+// MeanShift finds the position of an object in the 8-bit single channel
+// probability image, such as a histogram back projection, by iteratively
+// moving the window to the center of mass of the probabilities inside it.
+// Returns the final window and the sum of the probabilities in it as its
+// area.
+func MeanShift(probImage * Image, window Rect, criteria TermCriteria) (ConnectedComp, error) {
+  var ccomp C.CvConnectedComp
+  if probImage == nil { return ConnectedComp{}, errors.New("opencv: MeanShift needs a probability image") }
+  C.cvMeanShift(probImage.arr(), window.crect(), criteria.ccriteria(), &ccomp)
+  if err := lastError(); err != nil { return ConnectedComp{}, err }
+  return wrapConnectedComp(ccomp), nil
+}
+
+// CamShift finds the position of an object like MeanShift, then adapts the
+// window to the size and orientation of the object. Returns the final
+// window and the rotated box around the object.
+func CamShift(probImage * Image, window Rect, criteria TermCriteria) (ConnectedComp, Box2D, error) {
+  var ccomp C.CvConnectedComp
+  var cbox  C.CvBox2D
+  if probImage == nil { return ConnectedComp{}, Box2D{}, errors.New("opencv: CamShift needs a probability image") }
+  C.cvCamShift(probImage.arr(), window.crect(), criteria.ccriteria(), &ccomp, &cbox)
+  if err := lastError(); err != nil { return ConnectedComp{}, Box2D{}, err }
+  return wrapConnectedComp(ccomp), wrapBox2D(cbox), nil
+}
+
+// CamShiftTracker tracks an object through the frames of a video by the
+// hue histogram of its colors. Select the object with SetTarget, then call
+// Track for every following frame.
+type CamShiftTracker struct {
+  // Window is the search window, updated by every call to Track.
+  Window      Rect
+  // Criteria tells when to stop searching in every frame.
+  Criteria    TermCriteria
+  // Pixels with a saturation below SMin, or a value outside VMin to VMax,
+  // have an unreliable hue and are ignored.
+  SMin        int
+  VMin, VMax  int
+  hist        * Histogram
+  hsv         * Image
+  hue         * Image
+  mask        * Image
+  backproject * Image
+}
+
+// NewCamShiftTracker creates a tracker with a hue histogram of the given
+// number of bins, and the given termination criteria.
+func NewCamShiftTracker(bins int, criteria TermCriteria) (* CamShiftTracker, error) {
+  hist, err := NewHistogram([]int{bins}, HIST_ARRAY, [][]float32{{0, 180}}, true)
+  if err != nil { return nil, err }
+  return &CamShiftTracker{Criteria: criteria, SMin: 30, VMin: 10, VMax: 256, hist: hist}, nil
+}
+
+// Release releases the histogram and the buffers of the tracker.
+func (self * CamShiftTracker) Release() {
+  self.releaseBuffers()
+  if self.hist != nil { self.hist.Release() }
+  self.hist = nil
+}
+
+// releaseBuffers releases the per-frame buffers of the tracker.
+func (self * CamShiftTracker) releaseBuffers() {
+  for _, image := range []* Image{self.hsv, self.hue, self.mask, self.backproject} {
+    image.Release()
+  }
+  self.hsv, self.hue, self.mask, self.backproject = nil, nil, nil, nil
+}
+
+// prepare converts the 8-bit BGR frame to its hue plane and the mask of
+// pixels with a reliable hue, (re)allocating the buffers if needed.
+func (self * CamShiftTracker) prepare(frame * Image) error {
+  if frame == nil { return errors.New("opencv: CamShiftTracker needs a frame") }
+  if self.hist == nil { return errors.New("opencv: tracker was released") }
+  if self.hsv == nil || self.hsv.Width() != frame.Width() || self.hsv.Height() != frame.Height() {
+    self.releaseBuffers()
+    width, height   := frame.Width(), frame.Height()
+    self.hsv         = CreateImage(width, height, IPL_DEPTH_8U, 3)
+    self.hue         = CreateImage(width, height, IPL_DEPTH_8U, 1)
+    self.mask        = CreateImage(width, height, IPL_DEPTH_8U, 1)
+    self.backproject = CreateImage(width, height, IPL_DEPTH_8U, 1)
+    if self.hsv == nil || self.hue == nil || self.mask == nil || self.backproject == nil {
+      self.releaseBuffers()
+      return errors.New("opencv: could not allocate CamShiftTracker buffers")
+    }
+  }
+  C.cvCvtColor(frame.arr(), self.hsv.arr(), BGR2HSV)
+  vmin, vmax := self.VMin, self.VMax
+  if vmin > vmax { vmin, vmax = vmax, vmin }
+  C.cvInRangeS(self.hsv.arr(), C.cvScalar(0, C.double(self.SMin), C.double(vmin), 0),
+               C.cvScalar(180, 256, C.double(vmax), 0), self.mask.arr())
+  C.cvSplit(self.hsv.arr(), self.hue.arr(), nil, nil, nil)
+  return lastError()
+}
+
+// SetTarget sets the object to track to the region of the 8-bit BGR frame,
+// learning its hue histogram, and starts searching there.
+func (self * CamShiftTracker) SetTarget(frame * Image, region Rect) error {
+  if err := self.prepare(frame); err != nil { return err }
+  if region.Width <= 0 || region.Height <= 0 { return errors.New("opencv: CamShiftTracker needs a non-empty target region") }
+  C.cvSetImageROI(self.hue.cimage, region.crect())
+  C.cvSetImageROI(self.mask.cimage, region.crect())
+  err := CalcHist([]* Image{self.hue}, self.hist, false, self.mask)
+  C.cvResetImageROI(self.hue.cimage)
+  C.cvResetImageROI(self.mask.cimage)
+  if err != nil { return err }
+  // Scale the bins to 0 to 255 so the back projection is an 8-bit image.
+  _, max, _, _, err := self.hist.MinMax()
+  if err != nil { return err }
+  scale := 0.0
+  if max > 0 { scale = 255 / max }
+  C.cvConvertScale(self.hist.chist.bins, self.hist.chist.bins, C.double(scale), 0)
+  self.Window = region
+  return lastError()
+}
+
+// Histogram returns the hue histogram of the target.
+func (self * CamShiftTracker) Histogram() * Histogram {
+  return self.hist
+}
+
+// BackProjection returns the back projection of the hue histogram into the
+// last frame, masked to pixels with a reliable hue. The image belongs to
+// the tracker, do not release it.
+func (self * CamShiftTracker) BackProjection() * Image {
+  return self.backproject
+}
+
+// Track finds the target in the next 8-bit BGR frame, starting from the
+// current window. Returns the new window and the rotated box around the
+// target.
+func (self * CamShiftTracker) Track(frame * Image) (ConnectedComp, Box2D, error) {
+  if self.Window.Width <= 0 || self.Window.Height <= 0 {
+    return ConnectedComp{}, Box2D{}, errors.New("opencv: CamShiftTracker has no target")
+  }
+  if err := self.prepare(frame); err != nil { return ConnectedComp{}, Box2D{}, err }
+  if err := CalcBackProject([]* Image{self.hue}, self.backproject, self.hist); err != nil {
+    return ConnectedComp{}, Box2D{}, err
+  }
+  C.cvAnd(self.backproject.arr(), self.mask.arr(), self.backproject.arr(), nil)
+  comp, box, err := CamShift(self.backproject, self.Window, self.Criteria)
+  if err != nil { return ConnectedComp{}, Box2D{}, err }
+  // Keep searching where the target was lost, rather than in an empty window.
+  if comp.Rect.Width > 0 && comp.Rect.Height > 0 { self.Window = comp.Rect }
+  return comp, box, nil
+}
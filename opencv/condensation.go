@@ -0,0 +1,136 @@
+/*
+ConDensation particle filter.
+*/
+package opencv
+
+// #include <opencv/cv.h>
+import "C"
+import "errors"
+import "unsafe"
+
+// ParticleFilter estimates the state of a system with the ConDensation
+// algorithm, which represents the distribution of the state by a set of
+// weighted samples and so can follow several hypotheses at once. Every step,
+// weigh the samples by how well they match the measurement with
+// SetConfidences or Weigh, then call Update.
+type ParticleFilter struct {
+  ccond   * C.CvConDensation
+  states  int
+  samples int
+}
+
+// NewParticleFilter creates a particle filter with the given dimensions of
+// the state and measurement vectors, and number of samples. Set the
+// dynamics and initialize the samples with InitSampleSet before use.
+func NewParticleFilter(states, measurements, samples int) (* ParticleFilter, error) {
+  if states < 1 || measurements < 1 || samples < 1 {
+    return nil, errors.New("opencv: invalid particle filter dimensions")
+  }
+  ccond := C.cvCreateConDensation(C.int(states), C.int(measurements), C.int(samples))
+  if err := lastError(); err != nil { return nil, err }
+  if ccond == nil { return nil, errors.New("opencv: could not create particle filter") }
+  return &ParticleFilter{ccond, states, samples}, nil
+}
+
+// Release releases the filter.
+func (self * ParticleFilter) Release() {
+  if self.ccond != nil {
+    C.cvReleaseConDensation(&self.ccond)
+  }
+  self.ccond = nil
+}
+
+// States returns the dimension of the state vector.
+func (self * ParticleFilter) States() int {
+  return self.states
+}
+
+// SampleCount returns the number of samples.
+func (self * ParticleFilter) SampleCount() int {
+  return self.samples
+}
+
+// SetDynamics sets the states x states matrix that moves every sample to
+// the next time step.
+func (self * ParticleFilter) SetDynamics(dynamics [][]float64) error {
+  if self.ccond == nil { return errors.New("opencv: particle filter was released") }
+  if len(dynamics) != self.states { return errors.New("opencv: dynamics matrix does not match the particle filter") }
+  cdynam := (* [1 << 26]C.float)(unsafe.Pointer(self.ccond.DynamMatr))
+  for i, row := range dynamics {
+    if len(row) != self.states { return errors.New("opencv: dynamics matrix does not match the particle filter") }
+    for j, value := range row { cdynam[i * self.states + j] = C.float(value) }
+  }
+  return nil
+}
+
+// InitSampleSet distributes the samples uniformly between the lower and
+// upper bounds of every state component.
+func (self * ParticleFilter) InitSampleSet(lower, upper []float64) error {
+  if self.ccond == nil { return errors.New("opencv: particle filter was released") }
+  if len(lower) != self.states || len(upper) != self.states {
+    return errors.New("opencv: bounds do not match the particle filter")
+  }
+  clower := newCMat(self.states, 1, CV_32F, columnRows(lower)) ; defer releaseCMat(clower)
+  cupper := newCMat(self.states, 1, CV_32F, columnRows(upper)) ; defer releaseCMat(cupper)
+  C.cvConDensInitSampleSet(self.ccond, clower, cupper)
+  return lastError()
+}
+
+// sample returns the native state vector of a sample.
+func (self * ParticleFilter) sample(i int) * [1 << 26]C.float {
+  csamples := (* [1 << 26]* C.float)(unsafe.Pointer(self.ccond.flSamples))
+  return (* [1 << 26]C.float)(unsafe.Pointer(csamples[i]))
+}
+
+// Samples returns the state vectors of all samples.
+func (self * ParticleFilter) Samples() [][]float64 {
+  if self.ccond == nil { return nil }
+  samples := make([][]float64, self.samples)
+  for i := range samples {
+    csample   := self.sample(i)
+    samples[i] = make([]float64, self.states)
+    for j := range samples[i] { samples[i][j] = float64(csample[j]) }
+  }
+  return samples
+}
+
+// SetConfidences sets the confidence of every sample, in the order of
+// Samples, for the next Update.
+func (self * ParticleFilter) SetConfidences(confidences []float64) error {
+  if self.ccond == nil { return errors.New("opencv: particle filter was released") }
+  if len(confidences) != self.samples {
+    return errors.New("opencv: particle filter needs one confidence per sample")
+  }
+  cconf := (* [1 << 26]C.float)(unsafe.Pointer(self.ccond.flConfidence))
+  for i, confidence := range confidences { cconf[i] = C.float(confidence) }
+  return nil
+}
+
+// Weigh sets the confidence of every sample to the result of confidence
+// for its state vector, for the next Update.
+func (self * ParticleFilter) Weigh(confidence func(sample []float64) float64) error {
+  if self.ccond == nil { return errors.New("opencv: particle filter was released") }
+  samples     := self.Samples()
+  confidences := make([]float64, len(samples))
+  for i, sample := range samples { confidences[i] = confidence(sample) }
+  return self.SetConfidences(confidences)
+}
+
+// Update estimates the state from the weighted samples, then resamples them
+// by their confidences and moves them to the next time step. Returns the
+// estimated state.
+func (self * ParticleFilter) Update() ([]float64, error) {
+  if self.ccond == nil { return nil, errors.New("opencv: particle filter was released") }
+  C.cvConDensUpdateByTime(self.ccond)
+  if err := lastError(); err != nil { return nil, err }
+  return self.State(), nil
+}
+
+// State returns the state estimated by the last Update.
+func (self * ParticleFilter) State() []float64 {
+  if self.ccond == nil { return nil }
+  cstate := (* [1 << 26]C.float)(unsafe.Pointer(self.ccond.State))
+  state  := make([]float64, self.states)
+  for i := range state { state[i] = float64(cstate[i]) }
+  return state
+}
@@ -0,0 +1,156 @@
+/*
+Contour extraction from binary images.
+*/
+package opencv
+
+// #include <opencv/cv.h>
+//
+// static int seq_is_hole(CvSeq * seq) {
+//   return CV_IS_SEQ_HOLE(seq) != 0;
+// }
+//
+// static int seq_is_chain(CvSeq * seq) {
+//   return CV_IS_SEQ_CHAIN(seq) != 0;
+// }
+import "C"
+import "unsafe"
+import "errors"
+
+// Contour is a contour found in an image, copied out of OpenCV. The links
+// between contours reflect the hierarchy requested with the RETR_* mode:
+// Parent is the contour that encloses this one, Children are the contours
+// directly inside it, and Prev and Next are the siblings on the same level.
+type Contour struct {
+  Points   []Point
+  Hole     bool
+  Parent   * Contour
+  Children []* Contour
+  Prev     * Contour
+  Next     * Contour
+}
+
+// chainPoints decodes the points of a Freeman chain code contour.
+func chainPoints(cseq * C.CvSeq) []Point {
+  var reader C.CvChainPtReader
+  points := make([]Point, int(cseq.total))
+  C.cvStartReadChainPoints((* C.CvChain)(unsafe.Pointer(cseq)), &reader)
+  for i := range points {
+    points[i] = wrapPoint(C.cvReadChainPoint(&reader))
+  }
+  return points
+}
+
+// wrapContour copies a single contour out of OpenCV, without links.
+func wrapContour(cseq * C.CvSeq) * Contour {
+  contour := &Contour{Hole: C.seq_is_hole(cseq) != 0}
+  if C.seq_is_chain(cseq) != 0 {
+    contour.Points = chainPoints(cseq)
+  } else {
+    contour.Points = seqPoints(cseq)
+  }
+  return contour
+}
+
+// wrapContours copies the contour cseq, its siblings and all their children
+// out of OpenCV, preserving the links between them.
+func wrapContours(cseq * C.CvSeq, parent * Contour) []* Contour {
+  var contours []* Contour
+  var prev * Contour
+  for ; cseq != nil; cseq = cseq.h_next {
+    contour         := wrapContour(cseq)
+    contour.Parent   = parent
+    contour.Prev     = prev
+    if prev != nil { prev.Next = contour }
+    contour.Children = wrapContours(cseq.v_next, contour)
+    contours         = append(contours, contour)
+    prev             = contour
+  }
+  return contours
+}
+
+// contourHeaderSize returns the size of the sequence headers OpenCV should
+// use for contours found with the given CHAIN_* method.
+func contourHeaderSize(method int) C.int {
+  if method == CHAIN_CODE { return C.sizeof_CvChain }
+  return C.sizeof_CvContour
+}
+
+// FindContours finds the contours in the binary 8-bit single channel image,
+// where all non-zero pixels are treated as ones. mode is one of the RETR_*
+// constants and method one of the CHAIN_* constants or LINK_RUNS. The image
+// itself is not modified. Returns the first level of the contour hierarchy,
+// the other levels are reachable through the Children of each contour.
+func FindContours(image * Image, mode, method int) ([]* Contour, error) {
+  if image == nil { return nil, errors.New("opencv: FindContours needs an image") }
+  work := image.Clone()
+  if work == nil { return nil, errors.New("opencv: FindContours could not copy the image") }
+  defer work.Release()
+
+  cstorage := newStorage()
+  defer releaseStorage(cstorage)
+  var cfirst * C.CvSeq
+  C.cvFindContours(work.arr(), cstorage, &cfirst, contourHeaderSize(method),
+                   C.int(mode), C.int(method), C.cvPoint(0, 0))
+  if err := lastError(); err != nil { return nil, err }
+  return wrapContours(cfirst, nil), nil
+}
+
+// ContourScanner retrieves the contours of an image one by one, so huge
+// images can be processed without keeping all their contours in Go memory.
+type ContourScanner struct {
+  cscanner C.CvContourScanner
+  cstorage * C.CvMemStorage
+}
+
+// StartFindContours starts scanning the binary image for contours, see
+// FindContours for mode and method. To avoid a copy of very large images,
+// the scanner works in place, so the image is modified while scanning.
+// Call EndFindContours when done.
+func StartFindContours(image * Image, mode, method int) (* ContourScanner, error) {
+  if image == nil { return nil, errors.New("opencv: StartFindContours needs an image") }
+  cstorage := newStorage()
+  cscanner := C.cvStartFindContours(image.arr(), cstorage, contourHeaderSize(method),
+                                    C.int(mode), C.int(method), C.cvPoint(0, 0))
+  if err := lastError(); err != nil || cscanner == nil {
+    releaseStorage(cstorage)
+    if err == nil { err = errors.New("opencv: could not start finding contours") }
+    return nil, err
+  }
+  return &ContourScanner{cscanner, cstorage}, nil
+}
+
+// FindNextContour returns the next contour, without links to the other
+// contours, or nil when all contours have been found.
+func (self * ContourScanner) FindNextContour() (* Contour, error) {
+  if self.cscanner == nil { return nil, errors.New("opencv: contour scanner was ended") }
+  cseq := C.cvFindNextContour(self.cscanner)
+  if err := lastError(); err != nil { return nil, err }
+  if cseq == nil { return nil, nil }
+  return wrapContour(cseq), nil
+}
+
+// SubstituteContour replaces the contour last returned by FindNextContour
+// with the given one in the contour hierarchy that EndFindContours returns.
+// If contour is nil, the last contour is removed from the hierarchy.
+func (self * ContourScanner) SubstituteContour(contour * Contour) error {
+  if self.cscanner == nil { return errors.New("opencv: contour scanner was ended") }
+  var cseq * C.CvSeq
+  if contour != nil {
+    cseq = newPointSeq(self.cstorage, contour.Points)
+  }
+  C.cvSubstituteContour(self.cscanner, cseq)
+  return lastError()
+}
+
+// EndFindContours finishes the scan and returns the first level of the
+// contour hierarchy, like FindContours does. The scanner may not be used
+// afterwards.
+func (self * ContourScanner) EndFindContours() []* Contour {
+  if self.cscanner == nil { return nil }
+  cfirst   := C.cvEndFindContours(&self.cscanner)
+  contours := wrapContours(cfirst, nil)
+  releaseStorage(self.cstorage)
+  self.cscanner = nil
+  self.cstorage = nil
+  return contours
+}
@@ -0,0 +1,98 @@
+/*
+Corner detection and sub-pixel corner refinement.
+*/
+package opencv
+
+// #include <opencv/cv.h>
+import "C"
+import "errors"
+
+// GoodFeaturesParams are the parameters of GoodFeaturesToTrack. At most
+// MaxCorners corners are returned. Corners with a quality below
+// QualityLevel times the quality of the best corner are rejected, as are
+// corners closer than MinDistance to a better one. BlockSize is the size of
+// the neighbourhood considered for every pixel. If UseHarris is true, the
+// Harris detector with parameter K is used instead of the minimal eigen
+// value.
+type GoodFeaturesParams struct {
+  MaxCorners   int
+  QualityLevel float64
+  MinDistance  float64
+  BlockSize    int
+  UseHarris    bool
+  K            float64
+}
+
+// GoodFeaturesToTrack finds the strongest corners in the 8-bit or 32-bit
+// floating point single channel image. Only pixels where the optional mask
+// is non-zero are considered.
+func GoodFeaturesToTrack(image * Image, params GoodFeaturesParams, mask * Image) ([]Point2D32f, error) {
+  if image == nil { return nil, errors.New("opencv: GoodFeaturesToTrack needs an image") }
+  if params.MaxCorners < 1 { return nil, nil }
+  eig  := CreateImage(image.Width(), image.Height(), IPL_DEPTH_32F, 1)
+  if eig == nil { return nil, errors.New("opencv: could not allocate eigen image") }
+  defer eig.Release()
+  temp := CreateImage(image.Width(), image.Height(), IPL_DEPTH_32F, 1)
+  if temp == nil { return nil, errors.New("opencv: could not allocate temporary image") }
+  defer temp.Release()
+
+  ccorners := make([]C.CvPoint2D32f, params.MaxCorners)
+  ccount   := C.int(params.MaxCorners)
+  C.cvGoodFeaturesToTrack(image.arr(), eig.arr(), temp.arr(), &ccorners[0], &ccount,
+                          C.double(params.QualityLevel), C.double(params.MinDistance),
+                          mask.arr(), C.int(params.BlockSize), cbool(params.UseHarris),
+                          C.double(params.K))
+  if err := lastError(); err != nil { return nil, err }
+  corners := make([]Point2D32f, int(ccount))
+  for i := range corners { corners[i] = wrapPoint2D32f(ccorners[i]) }
+  return corners, nil
+}
+
+// FindCornerSubPix refines the corner locations found in the single channel
+// image to sub-pixel accuracy. win is half the size of the search window,
+// zero is half the size of the dead region in its middle, or -1, -1 for none.
+// The refinement stops according to criteria. Returns the refined corners.
+func FindCornerSubPix(image * Image, corners []Point2D32f, win, zero Size, criteria TermCriteria) ([]Point2D32f, error) {
+  if len(corners) == 0 { return nil, nil }
+  ccorners := make([]C.CvPoint2D32f, len(corners))
+  for i, corner := range corners { ccorners[i] = corner.cpoint() }
+  C.cvFindCornerSubPix(image.arr(), &ccorners[0], C.int(len(corners)),
+                       win.csize(), zero.csize(), criteria.ccriteria())
+  if err := lastError(); err != nil { return nil, err }
+  refined := make([]Point2D32f, len(corners))
+  for i := range refined { refined[i] = wrapPoint2D32f(ccorners[i]) }
+  return refined, nil
+}
+
+// CornerHarris calculates the Harris corner response of every pixel of the
+// single channel image into the 32-bit floating point dst image of the same
+// size, using a blockSize neighbourhood, a Sobel aperture of the given size
+// and Harris parameter k.
+func CornerHarris(image, dst * Image, blockSize, aperture int, k float64) error {
+  C.cvCornerHarris(image.arr(), dst.arr(), C.int(blockSize), C.int(aperture), C.double(k))
+  return lastError()
+}
+
+// CornerMinEigenVal calculates the minimal eigen value of the gradient
+// matrix of every pixel of the single channel image into the 32-bit floating
+// point dst image of the same size.
+func CornerMinEigenVal(image, dst * Image, blockSize, aperture int) error {
+  C.cvCornerMinEigenVal(image.arr(), dst.arr(), C.int(blockSize), C.int(aperture))
+  return lastError()
+}
+
+// CornerEigenValsAndVecs calculates both eigen values and eigen vectors of
+// the gradient matrix of every pixel of the single channel image. dst is a
+// 32-bit floating point image six times as wide as the image, which receives
+// l1, l2, x1, y1, x2, y2 for every pixel.
+func CornerEigenValsAndVecs(image, dst * Image, blockSize, aperture int) error {
+  C.cvCornerEigenValsAndVecs(image.arr(), dst.arr(), C.int(blockSize), C.int(aperture))
+  return lastError()
+}
+
+// PreCornerDetect calculates a feature map for corner detection of the
+// single channel image into the 32-bit floating point dst image.
+func PreCornerDetect(image, dst * Image, aperture int) error {
+  C.cvPreCornerDetect(image.arr(), dst.arr(), C.int(aperture))
+  return lastError()
+}
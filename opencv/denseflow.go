@@ -0,0 +1,188 @@
+/*
+Dense optical flow: Horn-Schunck, Lucas-Kanade and block matching.
+*/
+package opencv
+
+// #include <opencv/cv.h>
+import "C"
+import "errors"
+import "fmt"
+
+// FlowField is a dense motion field, with the horizontal and vertical
+// velocity of every pixel or block in two 32-bit floating point images.
+type FlowField struct {
+  VelX * Image
+  VelY * Image
+}
+
+// FlowVector is the velocity Dx, Dy sampled from a flow field at a point.
+type FlowVector struct {
+  Point Point
+  Dx    float32
+  Dy    float32
+}
+
+// NewFlowField allocates a flow field of the given size.
+// Returns nil if the field could not be allocated.
+func NewFlowField(width, height int) * FlowField {
+  velx := CreateImage(width, height, IPL_DEPTH_32F, 1)
+  vely := CreateImage(width, height, IPL_DEPTH_32F, 1)
+  if velx == nil || vely == nil {
+    if velx != nil { velx.Release() }
+    if vely != nil { vely.Release() }
+    return nil
+  }
+  return &FlowField{velx, vely}
+}
+
+// Release releases the velocity images of the flow field.
+func (self * FlowField) Release() {
+  if self.VelX != nil { self.VelX.Release() }
+  if self.VelY != nil { self.VelY.Release() }
+  self.VelX = nil
+  self.VelY = nil
+}
+
+// Width returns the width of the flow field, 0 once it was released.
+func (self * FlowField) Width() int {
+  if self.VelX == nil { return 0 }
+  return self.VelX.Width()
+}
+
+// Height returns the height of the flow field, 0 once it was released.
+func (self * FlowField) Height() int {
+  if self.VelX == nil { return 0 }
+  return self.VelX.Height()
+}
+
+// At returns the velocity at x, y in the flow field. Like indexing a slice,
+// At panics if x, y lies outside of the field.
+func (self * FlowField) At(x, y int) (dx, dy float32) {
+  if x < 0 || y < 0 || x >= self.Width() || y >= self.Height() {
+    panic(fmt.Sprintf("opencv: flow field index %d, %d out of range %dx%d", x, y, self.Width(), self.Height()))
+  }
+  cdx := C.cvGetReal2D(self.VelX.arr(), C.int(y), C.int(x))
+  cdy := C.cvGetReal2D(self.VelY.arr(), C.int(y), C.int(x))
+  if err := lastError(); err != nil { panic(err) }
+  return float32(cdx), float32(cdy)
+}
+
+// Sample samples the flow field on a grid with step cells between samples,
+// for example to draw it as arrows.
+func (self * FlowField) Sample(step int) []FlowVector {
+  if step < 1 { step = 1 }
+  var vectors []FlowVector
+  for y := 0; y < self.Height(); y += step {
+    for x := 0; x < self.Width(); x += step {
+      dx, dy := self.At(x, y)
+      vectors = append(vectors, FlowVector{Point{x, y}, dx, dy})
+    }
+  }
+  return vectors
+}
+
+// Visualize renders the flow field as an 8-bit BGR image, where the hue
+// shows the direction of the motion and the brightness its magnitude, with
+// maxMagnitude being full brightness. If maxMagnitude is 0 or less, the
+// largest magnitude in the field is used.
+func (self * FlowField) Visualize(maxMagnitude float64) (* Image, error) {
+  width, height := self.Width(), self.Height()
+  mag   := CreateImage(width, height, IPL_DEPTH_32F, 1) ; defer mag.Release()
+  angle := CreateImage(width, height, IPL_DEPTH_32F, 1) ; defer angle.Release()
+  hue   := CreateImage(width, height, IPL_DEPTH_8U, 1)  ; defer hue.Release()
+  sat   := CreateImage(width, height, IPL_DEPTH_8U, 1)  ; defer sat.Release()
+  val   := CreateImage(width, height, IPL_DEPTH_8U, 1)  ; defer val.Release()
+  hsv   := CreateImage(width, height, IPL_DEPTH_8U, 3)  ; defer hsv.Release()
+  for _, image := range []* Image{mag, angle, hue, sat, val, hsv} {
+    if image == nil { return nil, errors.New("opencv: could not allocate flow image") }
+  }
+
+  C.cvCartToPolar(self.VelX.arr(), self.VelY.arr(), mag.arr(), angle.arr(), 1)
+  if maxMagnitude <= 0 {
+    var cmin, cmax C.double
+    C.cvMinMaxLoc(mag.arr(), &cmin, &cmax, nil, nil, nil)
+    maxMagnitude = float64(cmax)
+  }
+  if maxMagnitude <= 0 { maxMagnitude = 1 }
+  // 8-bit hues run from 0 to 180, so halve the angle in degrees.
+  C.cvConvertScale(angle.arr(), hue.arr(), 0.5, 0)
+  C.cvSet(sat.arr(), C.cvScalarAll(255), nil)
+  C.cvConvertScale(mag.arr(), val.arr(), C.double(255 / maxMagnitude), 0)
+  C.cvMerge(hue.arr(), sat.arr(), val.arr(), nil, hsv.arr())
+
+  bgr := CreateImage(width, height, IPL_DEPTH_8U, 3)
+  if bgr == nil { return nil, errors.New("opencv: could not allocate flow image") }
+  C.cvCvtColor(hsv.arr(), bgr.arr(), HSV2BGR)
+  if err := lastError(); err != nil {
+    bgr.Release()
+    return nil, err
+  }
+  return bgr, nil
+}
+
+// flowResult returns flow, unless the OpenCV call that filled it failed.
+// If the wrapper allocated flow itself, it is released in that case.
+func flowResult(flow * FlowField, allocated bool) (* FlowField, error) {
+  if err := lastError(); err != nil {
+    if allocated { flow.Release() }
+    return nil, err
+  }
+  return flow, nil
+}
+
+// CalcOpticalFlowHS calculates the flow between the 8-bit single channel
+// frames prev and curr with the Horn-Schunck method, using Lagrangian
+// multiplier lambda. If flow is nil, a new flow field is returned, otherwise
+// flow is used as initial approximation and updated.
+func CalcOpticalFlowHS(prev, curr * Image, flow * FlowField, lambda float64, criteria TermCriteria) (* FlowField, error) {
+  if prev == nil || curr == nil { return nil, errors.New("opencv: CalcOpticalFlowHS needs two frames") }
+  useprev := flow != nil
+  if flow == nil { flow = NewFlowField(prev.Width(), prev.Height()) }
+  if flow == nil { return nil, errors.New("opencv: could not allocate flow field") }
+  C.cvCalcOpticalFlowHS(prev.arr(), curr.arr(), cbool(useprev), flow.VelX.arr(), flow.VelY.arr(),
+                        C.double(lambda), criteria.ccriteria())
+  return flowResult(flow, !useprev)
+}
+
+// CalcOpticalFlowLK calculates the flow between the 8-bit single channel
+// frames prev and curr with the Lucas-Kanade method, using windows of the
+// given size. If flow is nil, a new flow field is returned, otherwise the
+// result is stored in flow.
+func CalcOpticalFlowLK(prev, curr * Image, flow * FlowField, window Size) (* FlowField, error) {
+  if prev == nil || curr == nil { return nil, errors.New("opencv: CalcOpticalFlowLK needs two frames") }
+  allocated := flow == nil
+  if flow == nil { flow = NewFlowField(prev.Width(), prev.Height()) }
+  if flow == nil { return nil, errors.New("opencv: could not allocate flow field") }
+  C.cvCalcOpticalFlowLK(prev.arr(), curr.arr(), window.csize(), flow.VelX.arr(), flow.VelY.arr())
+  return flowResult(flow, allocated)
+}
+
+// BMFlowSize returns the size of the flow field CalcOpticalFlowBM produces
+// for frames of the given size.
+func BMFlowSize(frame, block, shift Size) (Size, error) {
+  if block.Width < 1 || block.Height < 1 || shift.Width < 1 || shift.Height < 1 {
+    return Size{}, errors.New("opencv: block and shift sizes must be positive")
+  }
+  if block.Width > frame.Width || block.Height > frame.Height {
+    return Size{}, errors.New("opencv: block size must not exceed the frame size")
+  }
+  return Size{(frame.Width  - block.Width  + shift.Width)  / shift.Width,
+              (frame.Height - block.Height + shift.Height) / shift.Height}, nil
+}
+
+// CalcOpticalFlowBM calculates the flow between the 8-bit single channel
+// frames prev and curr by matching blocks of the given size, spaced shift
+// apart, within maxRange around each block. The flow field has one entry
+// per block, see BMFlowSize. If flow is nil, a new flow field is returned,
+// otherwise flow is used as initial approximation and updated.
+func CalcOpticalFlowBM(prev, curr * Image, flow * FlowField, block, shift, maxRange Size) (* FlowField, error) {
+  if prev == nil || curr == nil { return nil, errors.New("opencv: CalcOpticalFlowBM needs two frames") }
+  size, err := BMFlowSize(Size{prev.Width(), prev.Height()}, block, shift)
+  if err != nil { return nil, err }
+  useprev := flow != nil
+  if flow == nil { flow = NewFlowField(size.Width, size.Height) }
+  if flow == nil { return nil, errors.New("opencv: could not allocate flow field") }
+  C.cvCalcOpticalFlowBM(prev.arr(), curr.arr(), block.csize(), shift.csize(), maxRange.csize(),
+                        cbool(useprev), flow.VelX.arr(), flow.VelY.arr())
+  return flowResult(flow, !useprev)
+}
@@ -0,0 +1,94 @@
+/*
+Earth Mover's Distance between weighted signatures.
+*/
+package opencv
+
+// #include <opencv/cv.h>
+import "C"
+import "errors"
+
+// Signature is a weighted set of points in feature space, such as a
+// compressed histogram. Weights[i] is the weight of Features[i]; all feature
+// vectors must have the same length.
+type Signature struct {
+  Weights  []float32
+  Features [][]float32
+}
+
+// DistanceFunc is a user-defined ground distance between two feature vectors.
+type DistanceFunc func(a, b []float32) float32
+
+// cmat converts the signature to the matrix layout cvCalcEMD2 expects: one
+// row per feature, holding the weight followed by the feature vector.
+func (self Signature) cmat() (* C.CvMat, error) {
+  if len(self.Weights) == 0 {
+    return nil, errors.New("opencv: empty signature")
+  }
+  if len(self.Features) != len(self.Weights) {
+    return nil, errors.New("opencv: signature needs one feature vector per weight")
+  }
+  dims := len(self.Features[0])
+  data := make([][]float64, len(self.Weights))
+  for i, weight := range self.Weights {
+    if len(self.Features[i]) != dims {
+      return nil, errors.New("opencv: signature feature vectors differ in length")
+    }
+    data[i]    = make([]float64, dims + 1)
+    data[i][0] = float64(weight)
+    for j, value := range self.Features[i] { data[i][j + 1] = float64(value) }
+  }
+  return newCMat(len(data), dims + 1, CV_32F, data), nil
+}
+
+// CalcEMD computes the Earth Mover's Distance between two signatures, the
+// minimal work needed to transform one into the other. disttype is one of
+// DIST_L1, DIST_L2 or DIST_C, or DIST_USER in which case distfunc is used as
+// the ground distance between features. If wantflow is true, the flow matrix
+// is returned as well: flow[i][j] is the amount of weight moved from
+// feature i of sig1 to feature j of sig2.
+func CalcEMD(sig1, sig2 Signature, disttype int, distfunc DistanceFunc, wantflow bool) (emd float64, flow [][]float32, err error) {
+  if disttype == DIST_USER && distfunc == nil {
+    return 0, nil, errors.New("opencv: DIST_USER needs a distance function")
+  }
+  csig1, err := sig1.cmat()
+  if err != nil { return 0, nil, err }
+  defer releaseCMat(csig1)
+  csig2, err := sig2.cmat()
+  if err != nil { return 0, nil, err }
+  defer releaseCMat(csig2)
+
+  // Rather than calling back into Go from OpenCV, the user-defined ground
+  // distances are calculated up front and passed in as a cost matrix.
+  var ccost * C.CvMat
+  if disttype == DIST_USER {
+    cost := make([][]float64, len(sig1.Weights))
+    for i := range cost {
+      cost[i] = make([]float64, len(sig2.Weights))
+      for j := range cost[i] {
+        cost[i][j] = float64(distfunc(sig1.Features[i], sig2.Features[j]))
+      }
+    }
+    ccost = newCMat(len(sig1.Weights), len(sig2.Weights), CV_32F, cost)
+    defer releaseCMat(ccost)
+  }
+
+  var cflow * C.CvMat
+  if wantflow {
+    cflow = newCMat(len(sig1.Weights), len(sig2.Weights), CV_32F, nil)
+    defer releaseCMat(cflow)
+  }
+
+  cemd := C.cvCalcEMD2(carr(csig1), carr(csig2), C.int(disttype), nil,
+                       carr(ccost), carr(cflow), nil, nil)
+  if err = lastError(); err != nil { return 0, nil, err }
+
+  if wantflow {
+    data := cmatData(cflow)
+    flow  = make([][]float32, len(data))
+    for i, row := range data {
+      flow[i] = make([]float32, len(row))
+      for j, value := range row { flow[i][j] = float32(value) }
+    }
+  }
+  return float64(cemd), flow, nil
+}
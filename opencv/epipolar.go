@@ -0,0 +1,131 @@
+/*
+Epipolar geometry: fundamental matrix, epipolar lines and homographies.
+*/
+package opencv
+
+// #include <opencv/cv.h>
+import "C"
+import "errors"
+
+// EpiLine is an epipolar line A*x + B*y + C = 0, normalized so that
+// A*A + B*B = 1.
+type EpiLine struct {
+  A, B, C float32
+}
+
+// newStatusMat creates a 1xN CV_8U matrix to receive inlier flags.
+func newStatusMat(count int) (* C.CvMat) {
+  return C.cvCreateMat(1, C.int(count), CV_8U)
+}
+
+// statusMask copies the inlier flags of a status matrix to Go.
+func statusMask(cstatus * C.CvMat) []bool {
+  count := int(cstatus.cols)
+  cdata := (* [1 << 26]C.uchar)(matData(cstatus))
+  mask  := make([]bool, count)
+  for i := range mask { mask[i] = cdata[i] != 0 }
+  return mask
+}
+
+// FindFundamentalMat calculates the fundamental matrix from corresponding
+// points in two images. method is FM_7POINT, which needs exactly 7 points
+// and can find up to three matrices, FM_8POINT, which needs at least 8
+// points, or RANSAC or LMEDS, which need at least 8 points and are robust
+// against outliers. For RANSAC, param1 is the maximal distance in pixels of
+// an inlier to its epipolar line; for RANSAC and LMEDS, param2 is the
+// desired confidence, for example 0.99. Returns the found matrices, and
+// which point pairs are inliers.
+func FindFundamentalMat(points1, points2 []Point2D32f, method int, param1, param2 float64) (matrices []Mat3, inliers []bool, err error) {
+  count := len(points1)
+  if count != len(points2) { return nil, nil, errors.New("opencv: FindFundamentalMat needs pairs of points") }
+  if (method == FM_7POINT && count != 7) || (method != FM_7POINT && count < 8) {
+    return nil, nil, errors.New("opencv: not enough points for FindFundamentalMat")
+  }
+  cpoints1 := newPoint2D32fMat(points1) ; defer releaseCMat(cpoints1)
+  cpoints2 := newPoint2D32fMat(points2) ; defer releaseCMat(cpoints2)
+  cstatus  := newStatusMat(count)       ; defer releaseCMat(cstatus)
+  rows     := 3
+  // The 7-point algorithm returns up to three matrices stacked in one.
+  if method == FM_7POINT { rows = 9 }
+  cfund    := newCMat(rows, 3, CV_64F, nil) ; defer releaseCMat(cfund)
+  cfound   := C.cvFindFundamentalMat(cpoints1, cpoints2, cfund, C.int(method),
+                                     C.double(param1), C.double(param2), cstatus)
+  if err = lastError(); err != nil { return nil, nil, err }
+  data    := cmatData(cfund)
+  matrices = make([]Mat3, int(cfound))
+  for i := range matrices {
+    for row := 0; row < 3; row++ { copy(matrices[i][row][:], data[i * 3 + row]) }
+  }
+  return matrices, statusMask(cstatus), nil
+}
+
+// ComputeCorrespondEpilines calculates the epipolar lines in one image for
+// points in the other image. whichImage is 1 if the points are in the first
+// image, or 2 if they are in the second one, of the image pair the
+// fundamental matrix was calculated for.
+func ComputeCorrespondEpilines(points []Point2D32f, whichImage int, fundamental Mat3) ([]EpiLine, error) {
+  if len(points) == 0 { return nil, nil }
+  cpoints := newPoint2D32fMat(points)        ; defer releaseCMat(cpoints)
+  cfund   := fundamental.cmat()              ; defer releaseCMat(cfund)
+  clines  := C.cvCreateMat(1, C.int(len(points)), C.CV_32FC3)
+  defer releaseCMat(clines)
+  C.cvComputeCorrespondEpilines(cpoints, C.int(whichImage), cfund, clines)
+  if err := lastError(); err != nil { return nil, err }
+  cdata := (* [1 << 26]C.CvPoint3D32f)(matData(clines))
+  lines := make([]EpiLine, len(points))
+  for i := range lines {
+    lines[i] = EpiLine{float32(cdata[i].x), float32(cdata[i].y), float32(cdata[i].z)}
+  }
+  return lines, nil
+}
+
+// FindHomography calculates the perspective transformation that maps the
+// src points onto the dst points. method is 0 to use all points, or RANSAC
+// or LMEDS to be robust against outliers. For RANSAC, threshold is the
+// maximal reprojection error in pixels of an inlier. Returns the homography
+// and which point pairs are inliers.
+func FindHomography(src, dst []Point2D32f, method int, threshold float64) (homography Mat3, inliers []bool, err error) {
+  count := len(src)
+  if count != len(dst) || count < 4 {
+    return homography, nil, errors.New("opencv: FindHomography needs at least four pairs of points")
+  }
+  csrc    := newPoint2D32fMat(src)      ; defer releaseCMat(csrc)
+  cdst    := newPoint2D32fMat(dst)      ; defer releaseCMat(cdst)
+  cstatus := newStatusMat(count)        ; defer releaseCMat(cstatus)
+  chomo   := newCMat(3, 3, CV_64F, nil) ; defer releaseCMat(chomo)
+  C.cvFindHomography(csrc, cdst, chomo, C.int(method), C.double(threshold), cstatus)
+  if err = lastError(); err != nil { return homography, nil, err }
+  return wrapMat3(chomo), statusMask(cstatus), nil
+}
+
+// ConvertPointsToHomogeneous converts 2D points to homogeneous coordinates,
+// with a Z of 1.
+func ConvertPointsToHomogeneous(points []Point2D32f) ([]Point3D32f, error) {
+  if len(points) == 0 { return nil, nil }
+  csrc := newPoint2D32fMat(points) ; defer releaseCMat(csrc)
+  cdst := C.cvCreateMat(1, C.int(len(points)), C.CV_32FC3)
+  defer releaseCMat(cdst)
+  C.cvConvertPointsHomogeneous(csrc, cdst)
+  if err := lastError(); err != nil { return nil, err }
+  cdata  := (* [1 << 26]C.CvPoint3D32f)(matData(cdst))
+  result := make([]Point3D32f, len(points))
+  for i := range result {
+    result[i] = Point3D32f{float32(cdata[i].x), float32(cdata[i].y), float32(cdata[i].z)}
+  }
+  return result, nil
+}
+
+// ConvertPointsFromHomogeneous converts points in homogeneous coordinates
+// to 2D points, by dividing by their Z.
+func ConvertPointsFromHomogeneous(points []Point3D32f) ([]Point2D32f, error) {
+  if len(points) == 0 { return nil, nil }
+  csrc := newPoint3D32fMat(points) ; defer releaseCMat(csrc)
+  cdst := C.cvCreateMat(1, C.int(len(points)), C.CV_32FC2)
+  defer releaseCMat(cdst)
+  C.cvConvertPointsHomogeneous(csrc, cdst)
+  if err := lastError(); err != nil { return nil, err }
+  cdata  := (* [1 << 26]C.CvPoint2D32f)(matData(cdst))
+  result := make([]Point2D32f, len(points))
+  for i := range result { result[i] = wrapPoint2D32f(cdata[i]) }
+  return result, nil
+}
@@ -0,0 +1,159 @@
+/*
+Fitting lines, ellipses, rectangles and circles to point sets.
+*/
+package opencv
+
+// #include <opencv/cv.h>
+import "C"
+import "unsafe"
+import "errors"
+import "fmt"
+
+// DistanceType is the distance function FitLine minimizes, one of DIST_L1,
+// DIST_L2, DIST_L12, DIST_FAIR, DIST_WELSCH or DIST_HUBER.
+type DistanceType int
+
+func (self DistanceType) String() string {
+  switch self {
+    case DIST_USER   : return "DIST_USER"
+    case DIST_L1     : return "DIST_L1"
+    case DIST_L2     : return "DIST_L2"
+    case DIST_C      : return "DIST_C"
+    case DIST_L12    : return "DIST_L12"
+    case DIST_FAIR   : return "DIST_FAIR"
+    case DIST_WELSCH : return "DIST_WELSCH"
+    case DIST_HUBER  : return "DIST_HUBER"
+  }
+  return fmt.Sprintf("DistanceType(%d)", int(self))
+}
+
+// Line2D is a 2D line through the point X0, Y0 with direction Vx, Vy,
+// which is normalized.
+type Line2D struct {
+  Vx, Vy, X0, Y0 float32
+}
+
+// Line3D is a 3D line through the point X0, Y0, Z0 with direction
+// Vx, Vy, Vz, which is normalized.
+type Line3D struct {
+  Vx, Vy, Vz, X0, Y0, Z0 float32
+}
+
+// fitLine fits a line to the points in carr, which has 2 or 3 dimensions.
+// param, reps and aeps are as described for FitLine2D.
+func fitLine(carr unsafe.Pointer, dist DistanceType, param, reps, aeps float64, line []C.float) error {
+  switch dist {
+    case DIST_L1, DIST_L2, DIST_L12, DIST_FAIR, DIST_WELSCH, DIST_HUBER:
+    default:
+      return fmt.Errorf("opencv: FitLine does not support %v", dist)
+  }
+  if carr == nil { return errors.New("opencv: FitLine needs at least two points") }
+  C.cvFitLine(carr, C.int(dist), C.double(param), C.double(reps), C.double(aeps), &line[0])
+  return lastError()
+}
+
+// FitLine2D fits a line to 2D points by minimizing the sum of the dist
+// distances between the points and the line. param is the numerical
+// parameter of DIST_FAIR, DIST_WELSCH and DIST_HUBER, 0 chooses the optimal
+// value. reps and aeps are the accuracy for the distance and the angle,
+// 0.01 is a good value for both.
+func FitLine2D(points []Point2D32f, dist DistanceType, param, reps, aeps float64) (Line2D, error) {
+  cmat := newPoint2D32fMat(points) ; defer releaseCMat(cmat)
+  line := make([]C.float, 4)
+  err  := fitLine(carr(cmat), dist, param, reps, aeps, line)
+  return Line2D{float32(line[0]), float32(line[1]), float32(line[2]), float32(line[3])}, err
+}
+
+// FitLine3D fits a line to 3D points, see FitLine2D for the parameters.
+func FitLine3D(points []Point3D32f, dist DistanceType, param, reps, aeps float64) (Line3D, error) {
+  cmat := newPoint3D32fMat(points) ; defer releaseCMat(cmat)
+  line := make([]C.float, 6)
+  err  := fitLine(carr(cmat), dist, param, reps, aeps, line)
+  return Line3D{float32(line[0]), float32(line[1]), float32(line[2]),
+                float32(line[3]), float32(line[4]), float32(line[5])}, err
+}
+
+// FitLine fits a line to the points of the contour, see FitLine2D for the
+// parameters.
+func (self * Contour) FitLine(dist DistanceType, param, reps, aeps float64) (Line2D, error) {
+  cmat := newPointMat(self.Points) ; defer releaseCMat(cmat)
+  line := make([]C.float, 4)
+  err  := fitLine(carr(cmat), dist, param, reps, aeps, line)
+  return Line2D{float32(line[0]), float32(line[1]), float32(line[2]), float32(line[3])}, err
+}
+
+// fitEllipse fits an ellipse to the points in carr, of which there are count.
+func fitEllipse(carr unsafe.Pointer, count int) (Box2D, error) {
+  if count < 5 { return Box2D{}, errors.New("opencv: FitEllipse2 needs at least five points") }
+  cbox := C.cvFitEllipse2(carr)
+  return wrapBox2D(cbox), lastError()
+}
+
+// FitEllipse2 fits an ellipse to at least five points in the least-squares
+// sense. Returns the box the ellipse is inscribed in.
+func FitEllipse2(points []Point2D32f) (Box2D, error) {
+  cmat := newPoint2D32fMat(points) ; defer releaseCMat(cmat)
+  return fitEllipse(carr(cmat), len(points))
+}
+
+// FitEllipse2 fits an ellipse to the points of the contour, see FitEllipse2.
+func (self * Contour) FitEllipse2() (Box2D, error) {
+  cmat := newPointMat(self.Points) ; defer releaseCMat(cmat)
+  return fitEllipse(carr(cmat), len(self.Points))
+}
+
+// minAreaRect finds the minimal area rotated rectangle around the points in
+// carr.
+func minAreaRect(carr unsafe.Pointer) (Box2D, error) {
+  if carr == nil { return Box2D{}, errors.New("opencv: MinAreaRect2 needs at least one point") }
+  cbox := C.cvMinAreaRect2(carr, nil)
+  return wrapBox2D(cbox), lastError()
+}
+
+// MinAreaRect2 returns the rotated rectangle of minimal area that contains
+// all the points.
+func MinAreaRect2(points []Point2D32f) (Box2D, error) {
+  cmat := newPoint2D32fMat(points) ; defer releaseCMat(cmat)
+  return minAreaRect(carr(cmat))
+}
+
+// MinAreaRect2 returns the rotated rectangle of minimal area that contains
+// the contour.
+func (self * Contour) MinAreaRect2() (Box2D, error) {
+  cmat := newPointMat(self.Points) ; defer releaseCMat(cmat)
+  return minAreaRect(carr(cmat))
+}
+
+// minEnclosingCircle finds the smallest circle around the points in carr.
+func minEnclosingCircle(carr unsafe.Pointer) (center Point2D32f, radius float32, err error) {
+  if carr == nil { return center, 0, errors.New("opencv: MinEnclosingCircle needs at least one point") }
+  var ccenter C.CvPoint2D32f
+  var cradius C.float
+  res := C.cvMinEnclosingCircle(carr, &ccenter, &cradius)
+  if err := lastError(); err != nil { return center, 0, err }
+  if res == 0 { return center, 0, errors.New("opencv: could not find an enclosing circle") }
+  return wrapPoint2D32f(ccenter), float32(cradius), nil
+}
+
+// MinEnclosingCircle returns the center and radius of the smallest circle
+// that contains all the points.
+func MinEnclosingCircle(points []Point2D32f) (center Point2D32f, radius float32, err error) {
+  cmat := newPoint2D32fMat(points) ; defer releaseCMat(cmat)
+  return minEnclosingCircle(carr(cmat))
+}
+
+// MinEnclosingCircle returns the smallest circle that contains the contour,
+// see MinEnclosingCircle.
+func (self * Contour) MinEnclosingCircle() (center Point2D32f, radius float32, err error) {
+  cmat := newPointMat(self.Points) ; defer releaseCMat(cmat)
+  return minEnclosingCircle(carr(cmat))
+}
+
+// BoxPoints returns the four corners of the rotated box.
+func BoxPoints(box Box2D) [4]Point2D32f {
+  var cpoints [4]C.CvPoint2D32f
+  var points  [4]Point2D32f
+  C.cvBoxPoints(box.cbox(), &cpoints[0])
+  for i, cpoint := range cpoints { points[i] = wrapPoint2D32f(cpoint) }
+  return points
+}
@@ -0,0 +1,384 @@
+/*
+Object detection with Haar classifier cascades.
+*/
+package opencv
+
+// #include <stdlib.h>
+// #include <string.h>
+// #include <opencv/cv.h>
+//
+// static CvHaarClassifierCascade * load_cascade(const char * filename) {
+//   void * object = cvLoad(filename, NULL, NULL, NULL);
+//   if (object == NULL) return NULL;
+//   if (!CV_IS_HAAR_CLASSIFIER(object)) {
+//     cvRelease(&object);
+//     return NULL;
+//   }
+//   return (CvHaarClassifierCascade *) object;
+// }
+//
+// static void alloc_classifier(CvHaarClassifier * classifier, int count) {
+//   // Use one block per classifier like the OpenCV loader, so that
+//   // cvReleaseHaarClassifierCascade frees it correctly.
+//   classifier->count = count;
+//   classifier->haar_feature = (CvHaarFeature *) cvAlloc(count * (sizeof(CvHaarFeature) +
+//     sizeof(float) + 2 * sizeof(int)) + (count + 1) * sizeof(float));
+//   classifier->threshold = (float *) (classifier->haar_feature + count);
+//   classifier->left      = (int *) (classifier->threshold + count);
+//   classifier->right     = classifier->left + count;
+//   classifier->alpha     = (float *) (classifier->right + count);
+// }
+//
+// static CvHaarClassifier * alloc_classifiers(int count) {
+//   CvHaarClassifier * classifiers;
+//   classifiers = (CvHaarClassifier *) cvAlloc(count * sizeof(CvHaarClassifier));
+//   memset(classifiers, 0, count * sizeof(CvHaarClassifier));
+//   return classifiers;
+// }
+//
+// static void save_cascade(const char * filename, CvHaarClassifierCascade * cascade) {
+//   cvSave(filename, cascade, NULL, NULL, cvAttrList(NULL, NULL));
+// }
+import "C"
+import "errors"
+import "fmt"
+import "io/fs"
+import "os"
+import "unsafe"
+
+// Cascade is a Haar classifier cascade, as used for face detection.
+type Cascade struct {
+  ccascade * C.CvHaarClassifierCascade
+}
+
+// HaarFlags are the flags for Cascade.Detect, a combination of
+// HAAR_DO_CANNY_PRUNING, HAAR_SCALE_IMAGE, HAAR_FIND_BIGGEST_OBJECT and
+// HAAR_DO_ROUGH_SEARCH.
+type HaarFlags int
+
+// DetectOptions are the options for Cascade.Detect. The search window is
+// scaled by ScaleFactor between passes. Candidate rectangles are grouped and
+// groups with less than MinNeighbors members are dropped, 0 keeps every
+// candidate without grouping. Objects smaller than MinSize are ignored.
+type DetectOptions struct {
+  ScaleFactor  float64
+  MinNeighbors int
+  MinSize      Size
+  Flags        HaarFlags
+}
+
+// DefaultDetectOptions are the options OpenCV uses by default.
+var DefaultDetectOptions = DetectOptions{ScaleFactor: 1.1, MinNeighbors: 3}
+
+// Detection is an object found by Cascade.Detect, with the amount of
+// candidate rectangles that were grouped into it.
+type Detection struct {
+  Rect      Rect
+  Neighbors int
+}
+
+// LoadCascade loads a Haar classifier cascade from an OpenCV XML or YAML file.
+func LoadCascade(filename string) (* Cascade, error) {
+  cfile    := cstr(filename) ; defer cfile.free()
+  ccascade := C.load_cascade(cfile)
+  if err := lastError(); err != nil { return nil, err }
+  if ccascade == nil {
+    return nil, errors.New("opencv: " + filename + " does not hold a Haar classifier cascade")
+  }
+  return &Cascade{ccascade}, nil
+}
+
+// LoadCascadeBytes loads a Haar classifier cascade from the contents of an
+// OpenCV XML file, for example one embedded in the program.
+func LoadCascadeBytes(data []byte) (* Cascade, error) {
+  // OpenCV can only load cascades from files, so go through a temporary one.
+  file, err := os.CreateTemp("", "opencv-cascade-*.xml")
+  if err != nil { return nil, err }
+  defer os.Remove(file.Name())
+  _, err = file.Write(data)
+  if cerr := file.Close(); err == nil { err = cerr }
+  if err != nil { return nil, err }
+  return LoadCascade(file.Name())
+}
+
+// LoadCascadeFS loads a Haar classifier cascade from the named OpenCV XML
+// file in fsys.
+func LoadCascadeFS(fsys fs.FS, name string) (* Cascade, error) {
+  data, err := fs.ReadFile(fsys, name)
+  if err != nil { return nil, err }
+  return LoadCascadeBytes(data)
+}
+
+// Release releases the memory associated with the cascade.
+func (self * Cascade) Release() {
+  if self.ccascade != nil {
+    C.cvReleaseHaarClassifierCascade(&self.ccascade)
+  }
+  self.ccascade = nil
+}
+
+// Size returns the size of the objects the cascade was trained on, which is
+// the smallest size at which it detects objects. A released cascade has an
+// empty size.
+func (self * Cascade) Size() Size {
+  if self.ccascade == nil { return Size{} }
+  return wrapSize(self.ccascade.orig_window_size)
+}
+
+// Detect finds the objects the cascade was trained for in the image.
+func (self * Cascade) Detect(image * Image, opts DetectOptions) ([]Detection, error) {
+  if image == nil { return nil, errors.New("opencv: Cascade.Detect needs an image") }
+  if self.ccascade == nil { return nil, errors.New("opencv: cascade was released") }
+  if opts.ScaleFactor <= 1 { return nil, errors.New("opencv: ScaleFactor must be larger than 1") }
+  cstorage := newStorage()
+  defer releaseStorage(cstorage)
+  cseq := C.cvHaarDetectObjects(image.arr(), self.ccascade, cstorage, C.double(opts.ScaleFactor),
+                                C.int(opts.MinNeighbors), C.int(opts.Flags), opts.MinSize.csize())
+  if err := lastError(); err != nil { return nil, err }
+  detections := make([]Detection, seqLimit(cseq, 0))
+  for i := range detections {
+    ccomp        := (* C.CvAvgComp)(seqElem(cseq, i))
+    detections[i] = Detection{wrapRect(ccomp.rect), int(ccomp.neighbors)}
+  }
+  return detections, nil
+}
+
+// Save saves the cascade to an OpenCV XML or YAML file.
+func (self * Cascade) Save(filename string) error {
+  if self.ccascade == nil { return errors.New("opencv: cascade was released") }
+  cfile := cstr(filename) ; defer cfile.free()
+  C.save_cascade(cfile, self.ccascade)
+  return lastError()
+}
+
+// HaarRect is one of the weighted rectangles that make up a Haar feature.
+type HaarRect struct {
+  Rect   Rect
+  Weight float32
+}
+
+// HaarFeature is a Haar-like feature of up to HAAR_FEATURE_MAX weighted
+// rectangles. Tilted features are rotated by 45 degrees.
+type HaarFeature struct {
+  Tilted bool
+  Rects  []HaarRect
+}
+
+// HaarClassifier is a weak classifier: a decision tree with a Haar feature
+// and a threshold in every node. Left and Right hold the index of the child
+// node, or minus the index into Alpha of the leaf value. Alpha has one entry
+// more than there are nodes.
+type HaarClassifier struct {
+  Features   []HaarFeature
+  Thresholds []float32
+  Left       []int
+  Right      []int
+  Alpha      []float32
+}
+
+// HaarStage is a stage of a cascade. An object passes the stage if the sum
+// of the values of its classifiers is at least Threshold. Next, Child and
+// Parent link the stages of tree cascades, with -1 for no link.
+type HaarStage struct {
+  Threshold   float32
+  Classifiers []HaarClassifier
+  Next        int
+  Child       int
+  Parent      int
+}
+
+// CascadeModel is an editable Go copy of a Haar classifier cascade.
+// WindowSize is the size of the objects it was trained on.
+type CascadeModel struct {
+  WindowSize Size
+  Stages     []HaarStage
+}
+
+// Model returns an editable Go copy of the cascade.
+func (self * Cascade) Model() * CascadeModel {
+  if self.ccascade == nil { return nil }
+  model  := &CascadeModel{WindowSize: wrapSize(self.ccascade.orig_window_size)}
+  count  := int(self.ccascade.count)
+  cstages := (* [1 << 20]C.CvHaarStageClassifier)(unsafe.Pointer(self.ccascade.stage_classifier))[:count:count]
+  model.Stages = make([]HaarStage, count)
+  for i := range cstages {
+    cstage := &cstages[i]
+    stage  := &model.Stages[i]
+    stage.Threshold = float32(cstage.threshold)
+    stage.Next      = int(cstage.next)
+    stage.Child     = int(cstage.child)
+    stage.Parent    = int(cstage.parent)
+    ccount := int(cstage.count)
+    cclassifiers := (* [1 << 20]C.CvHaarClassifier)(unsafe.Pointer(cstage.classifier))[:ccount:ccount]
+    stage.Classifiers = make([]HaarClassifier, ccount)
+    for j := range cclassifiers {
+      stage.Classifiers[j] = wrapHaarClassifier(&cclassifiers[j])
+    }
+  }
+  return model
+}
+
+// wrapHaarClassifier copies an OpenCV weak classifier to Go.
+func wrapHaarClassifier(cclassifier * C.CvHaarClassifier) HaarClassifier {
+  count       := int(cclassifier.count)
+  cfeatures   := (* [1 << 20]C.CvHaarFeature)(unsafe.Pointer(cclassifier.haar_feature))[:count:count]
+  cthresholds := (* [1 << 20]C.float)(unsafe.Pointer(cclassifier.threshold))[:count:count]
+  cleft       := (* [1 << 20]C.int)(unsafe.Pointer(cclassifier.left))[:count:count]
+  cright      := (* [1 << 20]C.int)(unsafe.Pointer(cclassifier.right))[:count:count]
+  calpha      := (* [1 << 20]C.float)(unsafe.Pointer(cclassifier.alpha))[:count + 1:count + 1]
+  classifier  := HaarClassifier {
+    make([]HaarFeature, count), make([]float32, count),
+    make([]int, count), make([]int, count), make([]float32, count + 1),
+  }
+  for i := 0; i < count; i++ {
+    feature := HaarFeature{Tilted: cfeatures[i].tilted != 0}
+    for _, crect := range cfeatures[i].rect {
+      // Unused rectangles of a feature have a weight of 0.
+      if crect.weight == 0 { continue }
+      feature.Rects = append(feature.Rects, HaarRect{wrapRect(crect.r), float32(crect.weight)})
+    }
+    classifier.Features[i]   = feature
+    classifier.Thresholds[i] = float32(cthresholds[i])
+    classifier.Left[i]       = int(cleft[i])
+    classifier.Right[i]      = int(cright[i])
+  }
+  for i := range classifier.Alpha { classifier.Alpha[i] = float32(calpha[i]) }
+  return classifier
+}
+
+// KeepStages drops all but the first count stages of the model, which makes
+// detection faster at the cost of more false positives. Links to dropped
+// stages are cleared.
+func (self * CascadeModel) KeepStages(count int) {
+  if count < 0 || count >= len(self.Stages) { return }
+  self.Stages = self.Stages[:count]
+  for i := range self.Stages {
+    stage := &self.Stages[i]
+    if stage.Next   >= count { stage.Next   = -1 }
+    if stage.Child  >= count { stage.Child  = -1 }
+    if stage.Parent >= count { stage.Parent = -1 }
+  }
+}
+
+// check verifies that the model is consistent, so it can be converted to
+// an OpenCV cascade without OpenCV reading outside of its arrays, or walking
+// the stages or decision trees in circles.
+func (self * CascadeModel) check() error {
+  if len(self.Stages) == 0 { return errors.New("opencv: cascade model has no stages") }
+  stages    := len(self.Stages)
+  validLink := func(link int) bool { return link == -1 || (link >= 0 && link < stages) }
+  for i, stage := range self.Stages {
+    if !validLink(stage.Next) || !validLink(stage.Child) || !validLink(stage.Parent) {
+      return fmt.Errorf("opencv: stage %d of cascade model links to a stage out of range", i)
+    }
+    // Like the OpenCV loader, require parents to precede their children, so
+    // the Parent links form a tree, and Child and Next to agree with it.
+    if stage.Parent >= i {
+      return fmt.Errorf("opencv: stage %d of cascade model does not follow its parent", i)
+    }
+    if stage.Child != -1 && self.Stages[stage.Child].Parent != i {
+      return fmt.Errorf("opencv: child of stage %d of cascade model has another parent", i)
+    }
+    if stage.Next != -1 && self.Stages[stage.Next].Parent != stage.Parent {
+      return fmt.Errorf("opencv: next stage of stage %d of cascade model has another parent", i)
+    }
+    for _, classifier := range stage.Classifiers {
+      count := len(classifier.Features)
+      if len(classifier.Thresholds) != count || len(classifier.Left) != count ||
+         len(classifier.Right) != count || len(classifier.Alpha) != count + 1 {
+        return errors.New("opencv: inconsistent Haar classifier in cascade model")
+      }
+      // A branch of node j is either a later node, j + 1 to count - 1, or a
+      // leaf -k whose value is Alpha[k]. OpenCV walks the tree until it
+      // reaches a leaf, so a branch back to an earlier node never ends.
+      validBranch := func(j, branch int) bool {
+        return (branch > j && branch < count) || (branch <= 0 && -branch <= count)
+      }
+      for j := 0; j < count; j++ {
+        if !validBranch(j, classifier.Left[j]) || !validBranch(j, classifier.Right[j]) {
+          return fmt.Errorf("opencv: Haar classifier in stage %d has a branch out of range", i)
+        }
+      }
+      for _, feature := range classifier.Features {
+        if len(feature.Rects) > HAAR_FEATURE_MAX {
+          return errors.New("opencv: Haar feature has too many rectangles")
+        }
+      }
+    }
+  }
+  // Siblings are chained by their Next links, which must not run in circles.
+  for i := range self.Stages {
+    steps := 0
+    for next := self.Stages[i].Next; next != -1; next = self.Stages[next].Next {
+      if steps++; steps > stages {
+        return fmt.Errorf("opencv: stage %d of cascade model is in a circle of Next links", i)
+      }
+    }
+  }
+  return nil
+}
+
+// Cascade converts the model to a cascade that can be used for detection.
+func (self * CascadeModel) Cascade() (* Cascade, error) {
+  if err := self.check(); err != nil { return nil, err }
+  ccascade := C.cvCreateHaarClassifierCascade(C.int(len(self.Stages)))
+  if ccascade == nil { return nil, errors.New("opencv: could not allocate cascade") }
+  ccascade.orig_window_size = self.WindowSize.csize()
+  count   := len(self.Stages)
+  cstages := (* [1 << 20]C.CvHaarStageClassifier)(unsafe.Pointer(ccascade.stage_classifier))[:count:count]
+  for i, stage := range self.Stages {
+    cstage          := &cstages[i]
+    cstage.threshold = C.float(stage.Threshold)
+    cstage.next      = C.int(stage.Next)
+    cstage.child     = C.int(stage.Child)
+    cstage.parent    = C.int(stage.Parent)
+    ccount          := len(stage.Classifiers)
+    if ccount == 0 { continue }
+    cstage.classifier = C.alloc_classifiers(C.int(ccount))
+    cstage.count      = C.int(ccount)
+    cclassifiers     := (* [1 << 20]C.CvHaarClassifier)(unsafe.Pointer(cstage.classifier))[:ccount:ccount]
+    for j, classifier := range stage.Classifiers {
+      fillHaarClassifier(&cclassifiers[j], classifier)
+    }
+  }
+  if err := lastError(); err != nil {
+    C.cvReleaseHaarClassifierCascade(&ccascade)
+    return nil, err
+  }
+  return &Cascade{ccascade}, nil
+}
+
+// fillHaarClassifier copies a weak classifier to OpenCV.
+func fillHaarClassifier(cclassifier * C.CvHaarClassifier, classifier HaarClassifier) {
+  count := len(classifier.Features)
+  C.alloc_classifier(cclassifier, C.int(count))
+  cfeatures   := (* [1 << 20]C.CvHaarFeature)(unsafe.Pointer(cclassifier.haar_feature))[:count:count]
+  cthresholds := (* [1 << 20]C.float)(unsafe.Pointer(cclassifier.threshold))[:count:count]
+  cleft       := (* [1 << 20]C.int)(unsafe.Pointer(cclassifier.left))[:count:count]
+  cright      := (* [1 << 20]C.int)(unsafe.Pointer(cclassifier.right))[:count:count]
+  calpha      := (* [1 << 20]C.float)(unsafe.Pointer(cclassifier.alpha))[:count + 1:count + 1]
+  for i, feature := range classifier.Features {
+    cfeature       := &cfeatures[i]
+    cfeature.tilted = cbool(feature.Tilted)
+    for k := range cfeature.rect {
+      cfeature.rect[k].r      = C.cvRect(0, 0, 0, 0)
+      cfeature.rect[k].weight = 0
+    }
+    for k, rect := range feature.Rects {
+      cfeature.rect[k].r      = rect.Rect.crect()
+      cfeature.rect[k].weight = C.float(rect.Weight)
+    }
+    cthresholds[i] = C.float(classifier.Thresholds[i])
+    cleft[i]       = C.int(classifier.Left[i])
+    cright[i]      = C.int(classifier.Right[i])
+  }
+  for i, alpha := range classifier.Alpha { calpha[i] = C.float(alpha) }
+}
+
+// Save saves the model as an OpenCV cascade XML or YAML file.
+func (self * CascadeModel) Save(filename string) error {
+  cascade, err := self.Cascade()
+  if err != nil { return err }
+  defer cascade.Release()
+  return cascade.Save(filename)
+}
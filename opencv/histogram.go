@@ -0,0 +1,281 @@
+/*
+Histograms: calculation, comparison, back projection and equalization.
+*/
+package opencv
+
+// #include <stdlib.h>
+// #include <opencv/cv.h>
+//
+// static int is_sparse_hist(CvHistogram * hist) {
+//   return CV_IS_SPARSE_HIST(hist);
+// }
+import "C"
+import "unsafe"
+import "errors"
+import "fmt"
+
+// Histogram is a multi-dimensional dense (HIST_ARRAY) or sparse (HIST_SPARSE)
+// histogram.
+type Histogram struct {
+  chist * C.CvHistogram
+}
+
+// newCRanges copies histogram bin ranges to C memory, as an array of
+// pointers to float arrays. Returns nil if ranges is empty. Free the result
+// with freeCRanges.
+func newCRanges(ranges [][]float32) (** C.float) {
+  if len(ranges) == 0 { return nil }
+  csize   := C.size_t(len(ranges)) * C.size_t(unsafe.Sizeof((* C.float)(nil)))
+  cranges := (* [MAX_DIM]* C.float)(C.malloc(csize))
+  for i, rang := range ranges {
+    cranges[i] = nil
+    if len(rang) == 0 { continue }
+    crange := (* [1 << 20]C.float)(C.malloc(C.size_t(len(rang)) * C.sizeof_float))
+    for j, bound := range rang { crange[j] = C.float(bound) }
+    cranges[i] = &crange[0]
+  }
+  return &cranges[0]
+}
+
+// freeCRanges frees bin ranges allocated by newCRanges.
+func freeCRanges(cranges ** C.float, count int) {
+  if cranges == nil { return }
+  array := (* [MAX_DIM]* C.float)(unsafe.Pointer(cranges))
+  for i := 0; i < count; i++ {
+    C.free(unsafe.Pointer(array[i]))
+  }
+  C.free(unsafe.Pointer(cranges))
+}
+
+// imageArrs returns the images as a slice of CvArr pointers, suitable to be
+// passed to OpenCV functions that take an array of planes.
+func imageArrs(images []* Image) []unsafe.Pointer {
+  arrs := make([]unsafe.Pointer, len(images))
+  for i, image := range images { arrs[i] = image.arr() }
+  return arrs
+}
+
+// checkPlanes checks that there is one image plane for every dimension of
+// the histogram, because OpenCV reads that many plane pointers.
+func checkPlanes(planes []* Image, hist * Histogram) error {
+  if hist == nil || hist.chist == nil { return errors.New("opencv: histogram is nil or was released") }
+  if dims := len(hist.Dims()); len(planes) != dims {
+    return fmt.Errorf("opencv: histogram with %d dimensions needs %d planes, got %d", dims, dims, len(planes))
+  }
+  for _, plane := range planes {
+    if plane.arr() == nil { return errors.New("opencv: histogram plane is nil") }
+  }
+  return nil
+}
+
+// checkRanges checks that ranges fit a histogram with the given amount of
+// bins per dimension. See NewHistogram for the meaning of ranges and uniform.
+func checkRanges(sizes []int, ranges [][]float32, uniform bool) error {
+  if len(ranges) != len(sizes) {
+    return fmt.Errorf("opencv: histogram needs ranges for %d dimensions, got %d", len(sizes), len(ranges))
+  }
+  for i, rang := range ranges {
+    bounds := 2
+    if !uniform { bounds = sizes[i] + 1 }
+    if len(rang) != bounds {
+      return fmt.Errorf("opencv: histogram range %d needs %d bounds, got %d", i, bounds, len(rang))
+    }
+  }
+  return nil
+}
+
+// NewHistogram creates a histogram with sizes[i] bins in dimension i.
+// histtype is HIST_ARRAY for a dense or HIST_SPARSE for a sparse histogram.
+// If uniform is true, ranges[i] holds the lower and upper boundary of
+// dimension i, otherwise it holds the sizes[i]+1 boundaries of every bin.
+// ranges may be nil, in which case SetRanges must be called before the
+// histogram is calculated.
+func NewHistogram(sizes []int, histtype int, ranges [][]float32, uniform bool) (* Histogram, error) {
+  if len(sizes) == 0 || len(sizes) > MAX_DIM {
+    return nil, fmt.Errorf("opencv: histogram needs 1 to %d dimensions, got %d", MAX_DIM, len(sizes))
+  }
+  for i, size := range sizes {
+    if size < 1 { return nil, fmt.Errorf("opencv: histogram dimension %d needs at least one bin", i) }
+  }
+  if ranges != nil {
+    if err := checkRanges(sizes, ranges, uniform); err != nil { return nil, err }
+  }
+  csizes  := make([]C.int, len(sizes))
+  for i, size := range sizes { csizes[i] = C.int(size) }
+  cranges := newCRanges(ranges) ; defer freeCRanges(cranges, len(ranges))
+  cdims   := C.int(len(sizes))
+  chist   := C.cvCreateHist(cdims, &csizes[0], C.int(histtype), cranges, cbool(uniform))
+  if err := lastError(); err != nil { return nil, err }
+  if chist == nil { return nil, errors.New("opencv: could not create histogram") }
+  return &Histogram{chist}, nil
+}
+
+// Release releases the memory associated with the histogram. Afterwards,
+// the methods of the histogram return an error.
+func (self * Histogram) Release() {
+  if self.chist != nil {
+    C.cvReleaseHist(&self.chist)
+  }
+  self.chist = nil
+}
+
+// SetRanges sets the bin ranges of the histogram. See NewHistogram for the
+// meaning of ranges and uniform.
+func (self * Histogram) SetRanges(ranges [][]float32, uniform bool) error {
+  if self.chist == nil { return errors.New("opencv: histogram was released") }
+  if err := checkRanges(self.Dims(), ranges, uniform); err != nil { return err }
+  cranges := newCRanges(ranges) ; defer freeCRanges(cranges, len(ranges))
+  C.cvSetHistBinRanges(self.chist, cranges, cbool(uniform))
+  return lastError()
+}
+
+// IsSparse returns true if the histogram is a sparse histogram.
+func (self * Histogram) IsSparse() bool {
+  if self.chist == nil { return false }
+  return C.is_sparse_hist(self.chist) != 0
+}
+
+// Dims returns the amount of bins in each dimension of the histogram.
+func (self * Histogram) Dims() []int {
+  if self.chist == nil { return nil }
+  csizes := make([]C.int, MAX_DIM)
+  cdims  := C.cvGetDims(self.chist.bins, &csizes[0])
+  sizes  := make([]int, int(cdims))
+  for i := range sizes { sizes[i] = int(csizes[i]) }
+  return sizes
+}
+
+// cindex converts a bin index to C ints. Returns nil unless the index has
+// one entry for every dimension of the histogram.
+func (self * Histogram) cindex(idx []int) []C.int {
+  if len(idx) == 0 || len(idx) != len(self.Dims()) { return nil }
+  cidx := make([]C.int, len(idx))
+  for i, index := range idx { cidx[i] = C.int(index) }
+  return cidx
+}
+
+// Bin returns the value of the bin at the given index, which must have one
+// entry for every dimension of the histogram. For a sparse histogram, bins
+// that were never set are 0.
+func (self * Histogram) Bin(idx ...int) (float64, error) {
+  if self.chist == nil { return 0, errors.New("opencv: histogram was released") }
+  cidx := self.cindex(idx)
+  if cidx == nil { return 0, errors.New("opencv: bin index needs one entry per histogram dimension") }
+  value := C.cvGetRealND(self.chist.bins, &cidx[0])
+  if err := lastError(); err != nil { return 0, err }
+  return float64(value), nil
+}
+
+// SetBin sets the value of the bin at the given index.
+func (self * Histogram) SetBin(value float64, idx ...int) error {
+  if self.chist == nil { return errors.New("opencv: histogram was released") }
+  cidx := self.cindex(idx)
+  if cidx == nil { return errors.New("opencv: bin index needs one entry per histogram dimension") }
+  C.cvSetRealND(self.chist.bins, &cidx[0], C.double(value))
+  return lastError()
+}
+
+// MinMax returns the minimum and maximum bin values of the histogram,
+// together with the indexes of the bins where they occur.
+func (self * Histogram) MinMax() (min, max float64, minidx, maxidx []int, err error) {
+  if self.chist == nil { return 0, 0, nil, nil, errors.New("opencv: histogram was released") }
+  var cmin, cmax C.float
+  cminidx := make([]C.int, MAX_DIM)
+  cmaxidx := make([]C.int, MAX_DIM)
+  C.cvGetMinMaxHistValue(self.chist, &cmin, &cmax, &cminidx[0], &cmaxidx[0])
+  if err := lastError(); err != nil { return 0, 0, nil, nil, err }
+  dims   := len(self.Dims())
+  minidx  = make([]int, dims)
+  maxidx  = make([]int, dims)
+  for i := 0; i < dims; i++ {
+    minidx[i] = int(cminidx[i])
+    maxidx[i] = int(cmaxidx[i])
+  }
+  return float64(cmin), float64(cmax), minidx, maxidx, nil
+}
+
+// Clear sets all bins of the histogram to 0.
+func (self * Histogram) Clear() error {
+  if self.chist == nil { return errors.New("opencv: histogram was released") }
+  C.cvClearHist(self.chist)
+  return lastError()
+}
+
+// Threshold clears all bins of the histogram that are below threshold.
+func (self * Histogram) Threshold(threshold float64) error {
+  if self.chist == nil { return errors.New("opencv: histogram was released") }
+  C.cvThreshHist(self.chist, C.double(threshold))
+  return lastError()
+}
+
+// Copy returns a full copy of the histogram.
+func (self * Histogram) Copy() (* Histogram, error) {
+  if self.chist == nil { return nil, errors.New("opencv: histogram was released") }
+  var cdst * C.CvHistogram
+  C.cvCopyHist(self.chist, &cdst)
+  if err := lastError(); err != nil {
+    if cdst != nil { C.cvReleaseHist(&cdst) }
+    return nil, err
+  }
+  if cdst == nil { return nil, errors.New("opencv: could not copy histogram") }
+  return &Histogram{cdst}, nil
+}
+
+// CalcHist calculates the histogram of one or more single channel image
+// planes, one plane for each dimension of the histogram. If accumulate is
+// true, the histogram is not cleared first, so the histogram of several
+// images can be calculated. Only pixels where the optional 8-bit mask is
+// non-zero are counted.
+func CalcHist(planes []* Image, hist * Histogram, accumulate bool, mask * Image) error {
+  if err := checkPlanes(planes, hist); err != nil { return err }
+  cplanes := imageArrs(planes)
+  C.cvCalcArrHist(&cplanes[0], hist.chist, cbool(accumulate), mask.arr())
+  return lastError()
+}
+
+// NormalizeHist normalizes the histogram so the sum of all bins equals factor.
+func NormalizeHist(hist * Histogram, factor float64) error {
+  if hist == nil || hist.chist == nil { return errors.New("opencv: histogram is nil or was released") }
+  C.cvNormalizeHist(hist.chist, C.double(factor))
+  return lastError()
+}
+
+// CompareHist compares two dense histograms using one of the COMP_CORREL,
+// COMP_CHISQR, COMP_INTERSECT or COMP_BHATTACHARYYA methods.
+func CompareHist(hist1, hist2 * Histogram, method int) (float64, error) {
+  if hist1 == nil || hist1.chist == nil || hist2 == nil || hist2.chist == nil {
+    return 0, errors.New("opencv: histogram is nil or was released")
+  }
+  result := C.cvCompareHist(hist1.chist, hist2.chist, C.int(method))
+  if err := lastError(); err != nil { return 0, err }
+  return float64(result), nil
+}
+
+// CalcBackProject calculates the back projection of the histogram into dst:
+// every pixel of dst is set to the value of the histogram bin that the
+// corresponding pixels of the planes fall in.
+func CalcBackProject(planes []* Image, dst * Image, hist * Histogram) error {
+  if err := checkPlanes(planes, hist); err != nil { return err }
+  cplanes := imageArrs(planes)
+  C.cvCalcArrBackProject(&cplanes[0], dst.arr(), hist.chist)
+  return lastError()
+}
+
+// CalcBackProjectPatch locates the histogram in the planes by comparing it
+// with the histogram of every patch of the given size using method, one of
+// the COMP_* constants. The histogram is normalized with factor. The
+// 32-bit floating point dst image must be smaller than the planes by the
+// patch size minus one.
+func CalcBackProjectPatch(planes []* Image, dst * Image, patch Size, hist * Histogram, method int, factor float64) error {
+  if err := checkPlanes(planes, hist); err != nil { return err }
+  cplanes := imageArrs(planes)
+  C.cvCalcArrBackProjectPatch(&cplanes[0], dst.arr(), patch.csize(), hist.chist, C.int(method), C.double(factor))
+  return lastError()
+}
+
+// EqualizeHist equalizes the histogram of the 8-bit single channel src image
+// into dst, which normalizes the brightness and increases the contrast.
+func EqualizeHist(src, dst * Image) error {
+  C.cvEqualizeHist(src.arr(), dst.arr())
+  return lastError()
+}
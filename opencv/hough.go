@@ -0,0 +1,176 @@
+/*
+Hough transforms to find lines and circles in images.
+*/
+package opencv
+
+// #include <opencv/cv.h>
+import "C"
+import "unsafe"
+import "errors"
+
+// PolarLine is a line in polar coordinates: Rho is the distance from the
+// origin to the line and Theta the angle in radians of the line's normal.
+type PolarLine struct {
+  Rho   float32
+  Theta float32
+}
+
+// LineSegment is a line segment between two points.
+type LineSegment struct {
+  Start Point
+  End   Point
+}
+
+// Circle is a circle with the given center and radius.
+type Circle struct {
+  Center Point2D32f
+  Radius float32
+}
+
+// HoughStandardParams are the parameters of HoughLines. Rho and Theta are
+// the distance and angle resolution of the accumulator, in pixels and
+// radians. Only lines with more than Threshold votes are returned, and at
+// most MaxLines of them, with the most voted lines first. A MaxLines of 0
+// means no limit.
+type HoughStandardParams struct {
+  Rho       float64
+  Theta     float64
+  Threshold int
+  MaxLines  int
+}
+
+// HoughMultiScaleParams are the parameters of HoughLinesMultiScale. The
+// coarse resolution is Rho and Theta, the accurate resolution is Rho divided
+// by RhoDivisor and Theta divided by ThetaDivisor. The other fields are as
+// in HoughStandardParams.
+type HoughMultiScaleParams struct {
+  Rho          float64
+  Theta        float64
+  Threshold    int
+  RhoDivisor   float64
+  ThetaDivisor float64
+  MaxLines     int
+}
+
+// HoughProbabilisticParams are the parameters of HoughLinesP. Segments are
+// at least MinLength long, and collinear segments with gaps smaller than
+// MaxGap between them are joined. The other fields are as in
+// HoughStandardParams.
+type HoughProbabilisticParams struct {
+  Rho       float64
+  Theta     float64
+  Threshold int
+  MinLength float64
+  MaxGap    float64
+  MaxLines  int
+}
+
+// HoughCirclesParams are the parameters of HoughCircles. Dp is the inverse
+// ratio of the accumulator resolution to the image resolution, MinDist the
+// minimum distance between circle centers. CannyThreshold is the upper
+// threshold of the internal Canny edge detector, AccThreshold the amount of
+// votes a circle center needs. Only circles with a radius between MinRadius
+// and MaxRadius are found, a MaxRadius of 0 means no limit. At most
+// MaxCircles circles are returned, 0 means no limit.
+type HoughCirclesParams struct {
+  Dp             float64
+  MinDist        float64
+  CannyThreshold float64
+  AccThreshold   float64
+  MinRadius      int
+  MaxRadius      int
+  MaxCircles     int
+}
+
+// seqLimit returns the amount of elements to copy out of the sequence, given
+// a limit where 0 means no limit.
+func seqLimit(cseq * C.CvSeq, limit int) int {
+  if cseq == nil { return 0 }
+  total := int(cseq.total)
+  if limit > 0 && limit < total { return limit }
+  return total
+}
+
+// houghPolarLines runs the standard or multi-scale Hough transform and copies
+// the found lines out of OpenCV.
+func houghPolarLines(image * Image, method int, rho, theta float64, threshold int,
+                     param1, param2 float64, max int) ([]PolarLine, error) {
+  if image == nil { return nil, errors.New("opencv: HoughLines needs an image") }
+  // cvHoughLines2 may modify its source, so work on a copy.
+  work := image.Clone()
+  if work == nil { return nil, errors.New("opencv: HoughLines could not copy the image") }
+  defer work.Release()
+  cstorage := newStorage()
+  defer releaseStorage(cstorage)
+  cseq := C.cvHoughLines2(work.arr(), unsafe.Pointer(cstorage), C.int(method),
+                          C.double(rho), C.double(theta), C.int(threshold),
+                          C.double(param1), C.double(param2))
+  if err := lastError(); err != nil { return nil, err }
+  lines := make([]PolarLine, seqLimit(cseq, max))
+  for i := range lines {
+    cline   := (* [2]C.float)(seqElem(cseq, i))
+    lines[i] = PolarLine{float32(cline[0]), float32(cline[1])}
+  }
+  return lines, nil
+}
+
+// HoughLines finds lines in the binary 8-bit single channel image using the
+// standard Hough transform (HOUGH_STANDARD). The image is not modified.
+func HoughLines(image * Image, params HoughStandardParams) ([]PolarLine, error) {
+  return houghPolarLines(image, HOUGH_STANDARD, params.Rho, params.Theta,
+                         params.Threshold, 0, 0, params.MaxLines)
+}
+
+// HoughLinesMultiScale finds lines in the binary 8-bit single channel image
+// using the multi-scale Hough transform (HOUGH_MULTI_SCALE). The image is
+// not modified.
+func HoughLinesMultiScale(image * Image, params HoughMultiScaleParams) ([]PolarLine, error) {
+  return houghPolarLines(image, HOUGH_MULTI_SCALE, params.Rho, params.Theta,
+                         params.Threshold, params.RhoDivisor, params.ThetaDivisor,
+                         params.MaxLines)
+}
+
+// HoughLinesP finds line segments in the binary 8-bit single channel image
+// using the probabilistic Hough transform (HOUGH_PROBABILISTIC). The image
+// is not modified.
+func HoughLinesP(image * Image, params HoughProbabilisticParams) ([]LineSegment, error) {
+  if image == nil { return nil, errors.New("opencv: HoughLinesP needs an image") }
+  // The probabilistic transform clears the pixels of the found segments in
+  // its source, so work on a copy.
+  work := image.Clone()
+  if work == nil { return nil, errors.New("opencv: HoughLinesP could not copy the image") }
+  defer work.Release()
+  cstorage := newStorage()
+  defer releaseStorage(cstorage)
+  cseq := C.cvHoughLines2(work.arr(), unsafe.Pointer(cstorage), HOUGH_PROBABILISTIC,
+                          C.double(params.Rho), C.double(params.Theta),
+                          C.int(params.Threshold), C.double(params.MinLength),
+                          C.double(params.MaxGap))
+  if err := lastError(); err != nil { return nil, err }
+  segments := make([]LineSegment, seqLimit(cseq, params.MaxLines))
+  for i := range segments {
+    cpoints    := (* [2]C.CvPoint)(seqElem(cseq, i))
+    segments[i] = LineSegment{wrapPoint(cpoints[0]), wrapPoint(cpoints[1])}
+  }
+  return segments, nil
+}
+
+// HoughCircles finds circles in the 8-bit single channel grayscale image
+// using the Hough gradient method (HOUGH_GRADIENT).
+func HoughCircles(image * Image, params HoughCirclesParams) ([]Circle, error) {
+  if image == nil { return nil, errors.New("opencv: HoughCircles needs an image") }
+  cstorage := newStorage()
+  defer releaseStorage(cstorage)
+  cseq := C.cvHoughCircles(image.arr(), unsafe.Pointer(cstorage), HOUGH_GRADIENT,
+                           C.double(params.Dp), C.double(params.MinDist),
+                           C.double(params.CannyThreshold), C.double(params.AccThreshold),
+                           C.int(params.MinRadius), C.int(params.MaxRadius))
+  if err := lastError(); err != nil { return nil, err }
+  circles := make([]Circle, seqLimit(cseq, params.MaxCircles))
+  for i := range circles {
+    ccircle   := (* [3]C.float)(seqElem(cseq, i))
+    center    := Point2D32f{float32(ccircle[0]), float32(ccircle[1])}
+    circles[i] = Circle{center, float32(ccircle[2])}
+  }
+  return circles, nil
+}
@@ -0,0 +1,194 @@
+/*
+Kalman filter.
+*/
+package opencv
+
+// #include <opencv/cv.h>
+import "C"
+import "errors"
+
+// KalmanFilter estimates the state of a linear system from noisy
+// measurements. All matrices are 32-bit floating point, and start out as
+// zero except for the identity matrices set by OpenCV; set at least the
+// transition and measurement matrices before use.
+type KalmanFilter struct {
+  ckalman      * C.CvKalman
+  states       int
+  measurements int
+  controls     int
+}
+
+// NewKalmanFilter creates a Kalman filter with the given dimensions of the
+// state, measurement and control vectors. controls may be 0 if the system
+// has no control input.
+func NewKalmanFilter(states, measurements, controls int) (* KalmanFilter, error) {
+  if states < 1 || measurements < 1 || controls < 0 {
+    return nil, errors.New("opencv: invalid Kalman filter dimensions")
+  }
+  ckalman := C.cvCreateKalman(C.int(states), C.int(measurements), C.int(controls))
+  if err := lastError(); err != nil { return nil, err }
+  if ckalman == nil { return nil, errors.New("opencv: could not create Kalman filter") }
+  return &KalmanFilter{ckalman, states, measurements, controls}, nil
+}
+
+// Release releases the filter. Getters return nil and setters an error
+// afterwards.
+func (self * KalmanFilter) Release() {
+  if self.ckalman != nil {
+    C.cvReleaseKalman(&self.ckalman)
+  }
+  self.ckalman = nil
+}
+
+// States returns the dimension of the state vector.
+func (self * KalmanFilter) States() int {
+  return self.states
+}
+
+// Measurements returns the dimension of the measurement vector.
+func (self * KalmanFilter) Measurements() int {
+  return self.measurements
+}
+
+// Controls returns the dimension of the control vector.
+func (self * KalmanFilter) Controls() int {
+  return self.controls
+}
+
+// TransitionMatrix returns the states x states matrix A that predicts the
+// next state from the current one.
+func (self * KalmanFilter) TransitionMatrix() [][]float64 {
+  if self.ckalman == nil { return nil }
+  return cmatData(self.ckalman.transition_matrix)
+}
+
+// SetTransitionMatrix sets the transition matrix A.
+func (self * KalmanFilter) SetTransitionMatrix(data [][]float64) error {
+  if self.ckalman == nil { return errors.New("opencv: Kalman filter was released") }
+  return setCMatData(self.ckalman.transition_matrix, data)
+}
+
+// ControlMatrix returns the states x controls matrix B that maps the
+// control vector to the state, or nil if the filter has no controls.
+func (self * KalmanFilter) ControlMatrix() [][]float64 {
+  if self.ckalman == nil { return nil }
+  if self.controls == 0 { return nil }
+  return cmatData(self.ckalman.control_matrix)
+}
+
+// SetControlMatrix sets the control matrix B.
+func (self * KalmanFilter) SetControlMatrix(data [][]float64) error {
+  if self.ckalman == nil { return errors.New("opencv: Kalman filter was released") }
+  if self.controls == 0 { return errors.New("opencv: Kalman filter has no controls") }
+  return setCMatData(self.ckalman.control_matrix, data)
+}
+
+// MeasurementMatrix returns the measurements x states matrix H that maps
+// the state to a measurement.
+func (self * KalmanFilter) MeasurementMatrix() [][]float64 {
+  if self.ckalman == nil { return nil }
+  return cmatData(self.ckalman.measurement_matrix)
+}
+
+// SetMeasurementMatrix sets the measurement matrix H.
+func (self * KalmanFilter) SetMeasurementMatrix(data [][]float64) error {
+  if self.ckalman == nil { return errors.New("opencv: Kalman filter was released") }
+  return setCMatData(self.ckalman.measurement_matrix, data)
+}
+
+// ProcessNoiseCov returns the states x states process noise covariance Q.
+func (self * KalmanFilter) ProcessNoiseCov() [][]float64 {
+  if self.ckalman == nil { return nil }
+  return cmatData(self.ckalman.process_noise_cov)
+}
+
+// SetProcessNoiseCov sets the process noise covariance Q.
+func (self * KalmanFilter) SetProcessNoiseCov(data [][]float64) error {
+  if self.ckalman == nil { return errors.New("opencv: Kalman filter was released") }
+  return setCMatData(self.ckalman.process_noise_cov, data)
+}
+
+// MeasurementNoiseCov returns the measurements x measurements measurement
+// noise covariance R.
+func (self * KalmanFilter) MeasurementNoiseCov() [][]float64 {
+  if self.ckalman == nil { return nil }
+  return cmatData(self.ckalman.measurement_noise_cov)
+}
+
+// SetMeasurementNoiseCov sets the measurement noise covariance R.
+func (self * KalmanFilter) SetMeasurementNoiseCov(data [][]float64) error {
+  if self.ckalman == nil { return errors.New("opencv: Kalman filter was released") }
+  return setCMatData(self.ckalman.measurement_noise_cov, data)
+}
+
+// ErrorCov returns the states x states a posteriori error covariance P.
+func (self * KalmanFilter) ErrorCov() [][]float64 {
+  if self.ckalman == nil { return nil }
+  return cmatData(self.ckalman.error_cov_post)
+}
+
+// SetErrorCov sets the a posteriori error covariance P, typically to
+// initialize it.
+func (self * KalmanFilter) SetErrorCov(data [][]float64) error {
+  if self.ckalman == nil { return errors.New("opencv: Kalman filter was released") }
+  return setCMatData(self.ckalman.error_cov_post, data)
+}
+
+// State returns the current, corrected state estimate.
+func (self * KalmanFilter) State() []float64 {
+  if self.ckalman == nil { return nil }
+  return columnData(self.ckalman.state_post)
+}
+
+// SetState sets the current state estimate, typically to initialize it.
+func (self * KalmanFilter) SetState(state []float64) error {
+  if self.ckalman == nil { return errors.New("opencv: Kalman filter was released") }
+  return setCMatData(self.ckalman.state_post, columnRows(state))
+}
+
+// Predict predicts the next state from the current one and the control
+// vector, which must be nil if the filter has no controls. Returns the
+// predicted state.
+func (self * KalmanFilter) Predict(control []float64) ([]float64, error) {
+  if self.ckalman == nil { return nil, errors.New("opencv: Kalman filter was released") }
+  var ccontrol * C.CvMat
+  if control != nil {
+    if len(control) != self.controls { return nil, errors.New("opencv: control vector does not match the Kalman filter") }
+    ccontrol = newCMat(self.controls, 1, CV_32F, columnRows(control))
+    defer releaseCMat(ccontrol)
+  } else if self.controls > 0 {
+    return nil, errors.New("opencv: Kalman filter needs a control vector")
+  }
+  cstate := C.cvKalmanPredict(self.ckalman, ccontrol)
+  if err := lastError(); err != nil { return nil, err }
+  return columnData(cstate), nil
+}
+
+// Correct corrects the predicted state with a measurement. Returns the
+// corrected state.
+func (self * KalmanFilter) Correct(measurement []float64) ([]float64, error) {
+  if self.ckalman == nil { return nil, errors.New("opencv: Kalman filter was released") }
+  if len(measurement) != self.measurements {
+    return nil, errors.New("opencv: measurement vector does not match the Kalman filter")
+  }
+  cmeasurement := newCMat(self.measurements, 1, CV_32F, columnRows(measurement))
+  defer releaseCMat(cmeasurement)
+  cstate := C.cvKalmanCorrect(self.ckalman, cmeasurement)
+  if err := lastError(); err != nil { return nil, err }
+  return columnData(cstate), nil
+}
+
+// columnRows converts a vector to the rows of a column matrix.
+func columnRows(vector []float64) [][]float64 {
+  rows := make([][]float64, len(vector))
+  for i, value := range vector { rows[i] = []float64{value} }
+  return rows
+}
+
+// columnData copies the values of a column matrix to a vector.
+func columnData(cmat * C.CvMat) []float64 {
+  rows   := cmatData(cmat)
+  vector := make([]float64, len(rows))
+  for i, row := range rows { vector[i] = row[0] }
+  return vector
+}
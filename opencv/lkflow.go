@@ -0,0 +1,129 @@
+/*
+Sparse pyramidal Lucas-Kanade optical flow.
+*/
+package opencv
+
+// #include <opencv/cv.h>
+import "C"
+import "errors"
+
+// LKTracker tracks feature points from frame to frame with the pyramidal
+// Lucas-Kanade method. It keeps the previous frame and the image pyramids
+// between calls to Track, so every pyramid is only built once.
+type LKTracker struct {
+  // Window is the size of the search window at each pyramid level.
+  Window   Size
+  // Level is the maximal pyramid level, 0 means no pyramids are used.
+  Level    int
+  // Criteria tells when to stop searching for a point at every level.
+  Criteria TermCriteria
+  // If MinEigen is true, Track returns the minimal eigen value of the
+  // gradient matrix of every point as its error (LKFLOW_GET_MIN_EIGENVALS),
+  // otherwise the difference between the patches around the points.
+  MinEigen bool
+  prev      * Image
+  prevpyr   * Image
+  currpyr   * Image
+  pyrready  bool
+  pyrlevel  int
+}
+
+// NewLKTracker creates a tracker with the given window size, maximal
+// pyramid level and termination criteria.
+func NewLKTracker(window Size, level int, criteria TermCriteria) * LKTracker {
+  return &LKTracker{Window: window, Level: level, Criteria: criteria}
+}
+
+// Release releases the frame and pyramid buffers of the tracker. The
+// tracker can still be used afterwards, it starts over with the next frame.
+func (self * LKTracker) Release() {
+  for _, image := range []* Image{self.prev, self.prevpyr, self.currpyr} {
+    if image != nil { image.Release() }
+  }
+  self.prev     = nil
+  self.prevpyr  = nil
+  self.currpyr  = nil
+  self.pyrready = false
+}
+
+// allocate (re)allocates the buffers of the tracker for frames like frame.
+func (self * LKTracker) allocate(frame * Image) error {
+  self.Release()
+  width, height := frame.Width(), frame.Height()
+  self.prev      = CreateImage(width, height, frame.Depth(), frame.Channels())
+  // The pyramid buffers must hold at least (width + 8) * height / 3 bytes,
+  // round the rows up so short frames get enough.
+  self.prevpyr   = CreateImage(width + 8, (height + 2) / 3, IPL_DEPTH_8U, 1)
+  self.currpyr   = CreateImage(width + 8, (height + 2) / 3, IPL_DEPTH_8U, 1)
+  if self.prev == nil || self.prevpyr == nil || self.currpyr == nil {
+    self.Release()
+    return errors.New("opencv: could not allocate LKTracker buffers")
+  }
+  return nil
+}
+
+// Track tracks the points of the previous frame into the 8-bit single
+// channel frame. guesses are optional initial guesses for the new positions
+// of the points (LKFLOW_INITIAL_GUESSES), pass nil to use none. Returns the
+// new positions, whether each point was found, and the tracking error of
+// each point. On the first frame, or when the size, depth or channels of
+// the frames change, there is nothing to track against yet, so the points
+// are returned unchanged.
+func (self * LKTracker) Track(frame * Image, points, guesses []Point2D32f) (next []Point2D32f, found []bool, trackerr []float32, err error) {
+  if frame == nil { return nil, nil, nil, errors.New("opencv: LKTracker needs a frame") }
+  if guesses != nil && len(guesses) != len(points) {
+    return nil, nil, nil, errors.New("opencv: LKTracker needs one guess per point")
+  }
+  if self.prev == nil || self.prev.Width() != frame.Width() || self.prev.Height() != frame.Height() ||
+     self.prev.Depth() != frame.Depth() || self.prev.Channels() != frame.Channels() {
+    if err = self.allocate(frame); err != nil { return nil, nil, nil, err }
+    C.cvCopy(frame.arr(), self.prev.arr(), nil)
+    next     = make([]Point2D32f, len(points))
+    found    = make([]bool, len(points))
+    trackerr = make([]float32, len(points))
+    copy(next, points)
+    for i := range found { found[i] = true }
+    return next, found, trackerr, lastError()
+  }
+
+  count  := len(points)
+  next     = make([]Point2D32f, count)
+  found    = make([]bool, count)
+  trackerr = make([]float32, count)
+  if count > 0 {
+    cprev   := make([]C.CvPoint2D32f, count)
+    cnext   := make([]C.CvPoint2D32f, count)
+    cstatus := make([]C.char, count)
+    cerror  := make([]C.float, count)
+    for i, point := range points { cprev[i] = point.cpoint() }
+    flags := 0
+    // The previous pyramid only has the levels it was built with.
+    if self.pyrready && self.pyrlevel == self.Level { flags |= LKFLOW_PYR_A_READY }
+    if self.MinEigen { flags |= LKFLOW_GET_MIN_EIGENVALS }
+    if guesses != nil {
+      flags |= LKFLOW_INITIAL_GUESSES
+      for i, guess := range guesses { cnext[i] = guess.cpoint() }
+    }
+    C.cvCalcOpticalFlowPyrLK(self.prev.arr(), frame.arr(), self.prevpyr.arr(), self.currpyr.arr(),
+                             &cprev[0], &cnext[0], C.int(count), self.Window.csize(),
+                             C.int(self.Level), &cstatus[0], &cerror[0],
+                             self.Criteria.ccriteria(), C.int(flags))
+    if err = lastError(); err != nil {
+      self.pyrready = false
+      return nil, nil, nil, err
+    }
+    for i := 0; i < count; i++ {
+      next[i]     = wrapPoint2D32f(cnext[i])
+      found[i]    = cstatus[i] != 0
+      trackerr[i] = float32(cerror[i])
+    }
+    // The pyramid of this frame is ready for use as the previous one.
+    self.prevpyr, self.currpyr = self.currpyr, self.prevpyr
+    self.pyrready = true
+    self.pyrlevel = self.Level
+  } else {
+    self.pyrready = false
+  }
+  C.cvCopy(frame.arr(), self.prev.arr(), nil)
+  return next, found, trackerr, lastError()
+}
@@ -0,0 +1,137 @@
+/*
+Helpers to move matrices between Go slices and OpenCV CvMat.
+*/
+package opencv
+
+// #include <opencv/cv.h>
+//
+// static void * mat_data(CvMat * mat) {
+//   return mat->data.ptr;
+// }
+import "C"
+import "fmt"
+import "unsafe"
+
+// newCMat creates an OpenCV matrix of the given CV_* element type and copies
+// data into it. data must have rows entries of cols values each.
+// Release the result with releaseCMat.
+func newCMat(rows, cols, mattype int, data [][]float64) (* C.CvMat) {
+  cmat := C.cvCreateMat(C.int(rows), C.int(cols), C.int(mattype))
+  for i, row := range data {
+    for j, value := range row {
+      C.cvSetReal2D(unsafe.Pointer(cmat), C.int(i), C.int(j), C.double(value))
+    }
+  }
+  return cmat
+}
+
+// cmatData copies the values of a single channel OpenCV matrix to Go.
+func cmatData(cmat * C.CvMat) [][]float64 {
+  rows := int(cmat.rows)
+  cols := int(cmat.cols)
+  data := make([][]float64, rows)
+  for i := range data {
+    data[i] = make([]float64, cols)
+    for j := range data[i] {
+      data[i][j] = float64(C.cvGetReal2D(unsafe.Pointer(cmat), C.int(i), C.int(j)))
+    }
+  }
+  return data
+}
+
+// setCMatData copies data into an existing single channel OpenCV matrix.
+// data must have as many rows and columns as the matrix.
+func setCMatData(cmat * C.CvMat, data [][]float64) error {
+  if len(data) != int(cmat.rows) {
+    return fmt.Errorf("opencv: matrix needs %d rows, got %d", int(cmat.rows), len(data))
+  }
+  for i, row := range data {
+    if len(row) != int(cmat.cols) {
+      return fmt.Errorf("opencv: matrix needs %d columns, got %d in row %d", int(cmat.cols), len(row), i)
+    }
+  }
+  for i, row := range data {
+    for j, value := range row {
+      C.cvSetReal2D(unsafe.Pointer(cmat), C.int(i), C.int(j), C.double(value))
+    }
+  }
+  return nil
+}
+
+// releaseCMat releases an OpenCV matrix. It does nothing for a nil matrix.
+func releaseCMat(cmat * C.CvMat) {
+  if cmat != nil {
+    C.cvReleaseMat(&cmat)
+  }
+}
+
+// carr returns the matrix as a CvArr pointer, or nil for a nil matrix.
+func carr(cmat * C.CvMat) unsafe.Pointer {
+  return unsafe.Pointer(cmat)
+}
+
+// matData returns a pointer to the data of an OpenCV matrix.
+func matData(cmat * C.CvMat) unsafe.Pointer {
+  return C.mat_data(cmat)
+}
+
+// newPointMat creates a 1xN CV_32SC2 matrix holding the points, as OpenCV
+// expects for point sets. Returns nil for an empty set.
+// Release the result with releaseCMat.
+func newPointMat(points []Point) (* C.CvMat) {
+  if len(points) == 0 { return nil }
+  cmat  := C.cvCreateMat(1, C.int(len(points)), C.CV_32SC2)
+  cdata := (* [1 << 26]C.CvPoint)(matData(cmat))
+  for i, point := range points { cdata[i] = point.cpoint() }
+  return cmat
+}
+
+// newPoint2D32fMat creates a 1xN CV_32FC2 matrix holding the points.
+// Returns nil for an empty set. Release the result with releaseCMat.
+func newPoint2D32fMat(points []Point2D32f) (* C.CvMat) {
+  if len(points) == 0 { return nil }
+  cmat  := C.cvCreateMat(1, C.int(len(points)), C.CV_32FC2)
+  cdata := (* [1 << 26]C.CvPoint2D32f)(matData(cmat))
+  for i, point := range points { cdata[i] = point.cpoint() }
+  return cmat
+}
+
+// newPoint3D32fMat creates a 1xN CV_32FC3 matrix holding the points.
+// Returns nil for an empty set. Release the result with releaseCMat.
+func newPoint3D32fMat(points []Point3D32f) (* C.CvMat) {
+  if len(points) == 0 { return nil }
+  cmat  := C.cvCreateMat(1, C.int(len(points)), C.CV_32FC3)
+  cdata := (* [1 << 26]C.CvPoint3D32f)(matData(cmat))
+  for i, point := range points {
+    cdata[i] = C.cvPoint3D32f(C.double(point.X), C.double(point.Y), C.double(point.Z))
+  }
+  return cmat
+}
+
+// cmat converts the vector to a 3x1 CV_64F OpenCV matrix.
+// Release the result with releaseCMat.
+func (self Vec3) cmat() (* C.CvMat) {
+  return newCMat(3, 1, CV_64F, [][]float64{{self[0]}, {self[1]}, {self[2]}})
+}
+
+// wrapVec3 copies the three values of a 3x1 or 1x3 OpenCV matrix to a Vec3.
+func wrapVec3(cmat * C.CvMat) Vec3 {
+  var vec Vec3
+  for i := range vec {
+    vec[i] = float64(C.cvGetReal1D(unsafe.Pointer(cmat), C.int(i)))
+  }
+  return vec
+}
+
+// cmat converts the matrix to a 3x3 CV_64F OpenCV matrix.
+// Release the result with releaseCMat.
+func (self Mat3) cmat() (* C.CvMat) {
+  return newCMat(3, 3, CV_64F, [][]float64{self[0][:], self[1][:], self[2][:]})
+}
+
+// wrapMat3 copies a 3x3 OpenCV matrix to a Mat3.
+func wrapMat3(cmat * C.CvMat) Mat3 {
+  var mat Mat3
+  for i, row := range cmatData(cmat) { copy(mat[i][:], row) }
+  return mat
+}
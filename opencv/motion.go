@@ -0,0 +1,158 @@
+/*
+Motion templates: motion history, motion gradients and motion segmentation.
+*/
+package opencv
+
+// #include <opencv/cv.h>
+import "C"
+import "errors"
+
+// MotionComponent is a connected region of motion found by
+// MotionHistory.Segment, with the direction of the motion in degrees.
+type MotionComponent struct {
+  ConnectedComp
+  Orientation float64
+}
+
+// UpdateMotionHistory updates the 32-bit floating point motion history
+// image mhi with the 8-bit silhouette of the moving objects: pixels where
+// the silhouette is non-zero are set to timestamp, pixels older than
+// timestamp - duration are cleared.
+func UpdateMotionHistory(silhouette, mhi * Image, timestamp, duration float64) error {
+  C.cvUpdateMotionHistory(silhouette.arr(), mhi.arr(), C.double(timestamp), C.double(duration))
+  return lastError()
+}
+
+// CalcMotionGradient calculates the orientation in degrees of the gradient
+// of the motion history image into the 32-bit floating point orientation
+// image. The 8-bit mask is set where the orientation is valid, because the
+// time difference in the neighbourhood lies between delta1 and delta2.
+// apertureSize is the size of the derivative kernel, 3, 5 or 7.
+func CalcMotionGradient(mhi, mask, orientation * Image, delta1, delta2 float64, apertureSize int) error {
+  C.cvCalcMotionGradient(mhi.arr(), mask.arr(), orientation.arr(), C.double(delta1), C.double(delta2),
+                         C.int(apertureSize))
+  return lastError()
+}
+
+// CalcGlobalOrientation calculates the average direction in degrees of the
+// motion within the mask, weighting recent motion more. Set an ROI on the
+// images to calculate it for a part of them.
+func CalcGlobalOrientation(orientation, mask, mhi * Image, timestamp, duration float64) (float64, error) {
+  corient := C.cvCalcGlobalOrientation(orientation.arr(), mask.arr(), mhi.arr(),
+                                       C.double(timestamp), C.double(duration))
+  if err := lastError(); err != nil { return 0, err }
+  return float64(corient), nil
+}
+
+// SegmentMotion splits the motion history image into separate regions of
+// motion, labelling them in the 32-bit floating point segmask. Regions are
+// separated by time gaps of at least segThreshold.
+func SegmentMotion(mhi, segmask * Image, timestamp, segThreshold float64) ([]ConnectedComp, error) {
+  cstorage := newStorage() ; defer releaseStorage(cstorage)
+  cseq     := C.cvSegmentMotion(mhi.arr(), segmask.arr(), cstorage, C.double(timestamp), C.double(segThreshold))
+  if err := lastError(); err != nil { return nil, err }
+  if cseq == nil { return nil, nil }
+  comps := make([]ConnectedComp, int(cseq.total))
+  for i := range comps {
+    comps[i] = wrapConnectedComp(* (* C.CvConnectedComp)(seqElem(cseq, i)))
+  }
+  return comps, nil
+}
+
+// MotionHistory keeps the motion history image of a video, and finds the
+// regions of motion in it and their direction. Feed it a silhouette of the
+// moving objects for every frame, for example a frame difference or a
+// background model foreground, with Update.
+type MotionHistory struct {
+  // Duration is the time in seconds motion is kept in the history.
+  Duration float64
+  // MinDelta and MaxDelta bound the time differences for which the motion
+  // gradient is valid. MaxDelta also separates motion regions.
+  MinDelta float64
+  MaxDelta float64
+  // MinArea discards motion regions with a smaller area.
+  MinArea  float64
+  mhi         * Image
+  mask        * Image
+  orientation * Image
+  segmask     * Image
+  timestamp   float64
+}
+
+// NewMotionHistory creates a motion history for frames of the given size.
+func NewMotionHistory(size Size, duration, minDelta, maxDelta float64) (* MotionHistory, error) {
+  self := &MotionHistory{Duration: duration, MinDelta: minDelta, MaxDelta: maxDelta}
+  self.mhi         = CreateImage(size.Width, size.Height, IPL_DEPTH_32F, 1)
+  self.mask        = CreateImage(size.Width, size.Height, IPL_DEPTH_8U, 1)
+  self.orientation = CreateImage(size.Width, size.Height, IPL_DEPTH_32F, 1)
+  self.segmask     = CreateImage(size.Width, size.Height, IPL_DEPTH_32F, 1)
+  if self.mhi == nil || self.mask == nil || self.orientation == nil || self.segmask == nil {
+    self.Release()
+    return nil, errors.New("opencv: could not allocate motion history buffers")
+  }
+  C.cvSetZero(self.mhi.arr())
+  return self, nil
+}
+
+// Release releases the buffers of the motion history.
+func (self * MotionHistory) Release() {
+  for _, image := range []* Image{self.mhi, self.mask, self.orientation, self.segmask} {
+    image.Release()
+  }
+  self.mhi, self.mask, self.orientation, self.segmask = nil, nil, nil, nil
+}
+
+// MHI returns the motion history image. The image belongs to the motion
+// history, do not release it.
+func (self * MotionHistory) MHI() * Image {
+  return self.mhi
+}
+
+// Orientation returns the orientation of the motion gradient and the mask
+// where it is valid, as of the last Update. The images belong to the motion
+// history, do not release them.
+func (self * MotionHistory) Orientation() (orientation, mask * Image) {
+  return self.orientation, self.mask
+}
+
+// Update adds the 8-bit silhouette of the moving objects at timestamp, in
+// seconds, to the history and recalculates the motion gradient.
+func (self * MotionHistory) Update(silhouette * Image, timestamp float64) error {
+  if self.mhi == nil { return errors.New("opencv: motion history was released") }
+  if silhouette == nil || silhouette.Width() != self.mhi.Width() || silhouette.Height() != self.mhi.Height() {
+    return errors.New("opencv: silhouette size does not match the motion history")
+  }
+  if err := UpdateMotionHistory(silhouette, self.mhi, timestamp, self.Duration); err != nil { return err }
+  self.timestamp = timestamp
+  return CalcMotionGradient(self.mhi, self.mask, self.orientation, self.MinDelta, self.MaxDelta, 3)
+}
+
+// GlobalOrientation returns the direction in degrees of all recent motion.
+func (self * MotionHistory) GlobalOrientation() (float64, error) {
+  if self.mhi == nil { return 0, errors.New("opencv: motion history was released") }
+  return CalcGlobalOrientation(self.orientation, self.mask, self.mhi, self.timestamp, self.Duration)
+}
+
+// Segment splits the recent motion into separate regions and returns them
+// with their direction.
+func (self * MotionHistory) Segment() ([]MotionComponent, error) {
+  if self.mhi == nil { return nil, errors.New("opencv: motion history was released") }
+  comps, err := SegmentMotion(self.mhi, self.segmask, self.timestamp, self.MaxDelta)
+  if err != nil { return nil, err }
+  var result []MotionComponent
+  for _, comp := range comps {
+    if comp.Area < self.MinArea || comp.Rect.Width <= 0 || comp.Rect.Height <= 0 { continue }
+    // Calculate the orientation within the bounding rectangle of the region.
+    croi := comp.Rect.crect()
+    for _, image := range []* Image{self.orientation, self.mask, self.mhi} {
+      C.cvSetImageROI(image.cimage, croi)
+    }
+    orientation, err := CalcGlobalOrientation(self.orientation, self.mask, self.mhi, self.timestamp, self.Duration)
+    for _, image := range []* Image{self.orientation, self.mask, self.mhi} {
+      C.cvResetImageROI(image.cimage)
+    }
+    if err != nil { return nil, err }
+    result = append(result, MotionComponent{comp, orientation})
+  }
+  return result, nil
+}
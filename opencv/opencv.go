@@ -153,6 +153,7 @@ const (
   STEREO_BM_BASIC              = 0
   STEREO_BM_FISH_EYE           = 1
   STEREO_BM_NARROW             = 2
+  STEREO_GC_OCCLUDED           = 32767
   EIGOBJ_NO_CALLBACK           = 0
   EIGOBJ_INPUT_CALLBACK        = 1
   EIGOBJ_OUTPUT_CALLBACK       = 2
@@ -231,6 +232,7 @@ const (
   CHAIN_APPROX_TC89_L1         = 3
   CHAIN_APPROX_TC89_KCOS       = 4
   LINK_RUNS                    = 5
+  DIST_USER                    = -1
   DIST_L1                      = 1
   DIST_L2                      = 2
   DIST_C                       = 3
@@ -340,6 +342,9 @@ const (
   IPL_DEPTH_8U                    = 8
   IPL_DEPTH_16U                   = 16
   IPL_DEPTH_32F                   = 32
+  IPL_DEPTH_8S                    = -IPL_DEPTH_SIGN + 8
+  IPL_DEPTH_16S                   = -IPL_DEPTH_SIGN + 16
+  IPL_DEPTH_32S                   = -IPL_DEPTH_SIGN + 32
   IPL_DATA_ORDER_PIXEL            = 0
   IPL_DATA_ORDER_PLANE            = 1
   IPL_ORIGIN_TL                   = 0
@@ -589,7 +594,26 @@ func ErrorStr(status int) string {
   return C.GoString(cstr)
 }
 
-// RedirectError and other error callbacks not supported 
+// RedirectError and other error callbacks not supported
+
+// CvError is an error that was reported by OpenCV through its error status.
+type CvError struct {
+  Status  int
+  Message string
+}
+
+func (self * CvError) Error() string {
+  return fmt.Sprintf("opencv: %s (status %d)", self.Message, self.Status)
+}
+
+// lastError returns the pending OpenCV error status as a *CvError, or nil if
+// no error occured. The error status is reset to OK afterwards.
+func lastError() error {
+  status := GetErrStatus()
+  if status == 0 { return nil }
+  SetErrStatus(0)
+  return &CvError{status, ErrorStr(status)}
+}
 
 
 
@@ -634,6 +658,46 @@ func LoadImage(filename string, iscolor int) * Image {
   return WrapImage(cimage)
 }
 
+// CreateImage creates an image of the given size, IPL_DEPTH_* depth and
+// number of channels. Returns nil if the image could not be allocated.
+func CreateImage(width, height, depth, channels int) * Image {
+  csize   := C.cvSize(C.int(width), C.int(height))
+  cimage  := C.cvCreateImage(csize, C.int(depth), C.int(channels))
+  return WrapImage(cimage)
+}
+
+// Clone returns a full copy of the image, including its data.
+func (self * Image) Clone() * Image {
+  return WrapImage(C.cvCloneImage(self.cimage))
+}
+
+// Width returns the width of the image in pixels.
+func (self * Image) Width() int {
+  return int(self.cimage.width)
+}
+
+// Height returns the height of the image in pixels.
+func (self * Image) Height() int {
+  return int(self.cimage.height)
+}
+
+// Depth returns the IPL_DEPTH_* pixel depth of the image.
+func (self * Image) Depth() int {
+  return int(self.cimage.depth)
+}
+
+// Channels returns the amount of color channels of the image.
+func (self * Image) Channels() int {
+  return int(self.cimage.nChannels)
+}
+
+// arr returns the image as a CvArr pointer, or nil for a nil image, so
+// optional image arguments such as masks can be passed on directly.
+func (self * Image) arr() unsafe.Pointer {
+  if self == nil || self.cimage == nil { return nil }
+  return unsafe.Pointer(self.cimage)
+}
+
 func (self *C.IplImage) releaseimage() {
   C.cvReleaseImage(&self)
 }
@@ -670,6 +734,7 @@ func (self * Image) Save(filename string) (* Image) {
 
 // Release releases the memory associated with the block
 func (self * Image) Release() {
+  if self == nil { return }
   if self.cimage != nil {
     self.cimage.releaseimage()
   }  
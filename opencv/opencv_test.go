@@ -1,12 +1,851 @@
+package opencv_test
+
+import "bytes"
+import "math"
+import "strings"
 import "testing"
 import "opencv"
 
 
 
 func TestLoad(t *testing.T) {
-  filename := "test_input.png"
-  image    := opencv.LoadImage(filename, 0)  
+  // The fixture lives in the repository root, next to test-opencv.go.
+  filename := "../test_input.png"
+  image    := opencv.LoadImage(filename, 0)
+  if image == nil { t.Fatal("Could not load " + filename) }
+  image.Release()
+}
+
+
+
+func TestHistogram(t *testing.T) {
+  image    := opencv.LoadImage("../test_input.png", opencv.LOAD_IMAGE_GRAYSCALE)
+  if image == nil { t.Fatal("Could not load ../test_input.png") }
+  defer       image.Release()
+  ranges   := [][]float32{{0, 256}}
+  hist, err := opencv.NewHistogram([]int{16}, opencv.HIST_ARRAY, ranges, true)
+  if err != nil { t.Fatal(err) }
+  defer       hist.Release()
+  err       = opencv.CalcHist([]*opencv.Image{image}, hist, false, nil)
+  if err != nil { t.Fatal(err) }
+  total    := 0.0
+  for i := 0; i < 16; i++ {
+    value, err := hist.Bin(i)
+    if err != nil { t.Fatal(err) }
+    total += value
+  }
+  if int(total) != image.Width() * image.Height() {
+    t.Errorf("Histogram should count every pixel, got %v", total)
+  }
+}
+
+
+
+func TestHistogramRanges(t *testing.T) {
+  if _, err := opencv.NewHistogram([]int{16}, opencv.HIST_ARRAY, [][]float32{{0}}, true); err == nil {
+    t.Errorf("A uniform range with one bound should be rejected")
+  }
+  if _, err := opencv.NewHistogram([]int{4}, opencv.HIST_ARRAY, [][]float32{{0, 1, 2}}, false); err == nil {
+    t.Errorf("A non-uniform range with too few bounds should be rejected")
+  }
+  if _, err := opencv.NewHistogram(make([]int, opencv.MAX_DIM + 1), opencv.HIST_ARRAY, nil, true); err == nil {
+    t.Errorf("More than MAX_DIM dimensions should be rejected")
+  }
+}
+
+
+
+func TestHistogramPlanes(t *testing.T) {
+  ranges    := [][]float32{{0, 180}, {0, 256}}
+  hist, err := opencv.NewHistogram([]int{30, 32}, opencv.HIST_ARRAY, ranges, true)
+  if err != nil { t.Fatal(err) }
+  image     := opencv.CreateImage(8, 8, opencv.IPL_DEPTH_8U, 1)
+  defer        image.Release()
+  if err := opencv.CalcHist([]*opencv.Image{image}, hist, false, nil); err == nil {
+    t.Errorf("A 2D histogram of a single plane should be rejected")
+  }
+  if err := opencv.CalcBackProject([]*opencv.Image{image}, image, hist); err == nil {
+    t.Errorf("A back projection of a 2D histogram from a single plane should be rejected")
+  }
+  hist.Release()
+  if _, err := hist.Bin(0, 0); err == nil {
+    t.Errorf("Reading a bin of a released histogram should fail")
+  }
+  if err := opencv.CalcHist([]*opencv.Image{image, image}, hist, false, nil); err == nil {
+    t.Errorf("Calculating a released histogram should fail")
+  }
+}
+
+
+
+func TestCalcEMD(t *testing.T) {
+  sig1 := opencv.Signature{Weights: []float32{1}, Features: [][]float32{{0, 0}}}
+  sig2 := opencv.Signature{Weights: []float32{1}, Features: [][]float32{{3, 4}}}
+  emd, flow, err := opencv.CalcEMD(sig1, sig2, opencv.DIST_L2, nil, true)
+  if err != nil { t.Fatal(err) }
+  if diff := emd - 5; diff > 1e-4 || diff < -1e-4 {
+    t.Errorf("EMD between two unit weights 5 apart should be 5, got %v", emd)
+  }
+  if len(flow) != 1 || len(flow[0]) != 1 || flow[0][0] != 1 {
+    t.Errorf("The whole weight should flow from sig1 to sig2, got %v", flow)
+  }
+  if _, _, err := opencv.CalcEMD(sig1, sig2, opencv.DIST_USER, nil, false); err == nil {
+    t.Errorf("DIST_USER without a distance function should be rejected")
+  }
+  bad := opencv.Signature{Weights: []float32{1, 1}, Features: [][]float32{{0, 0}}}
+  if _, _, err := opencv.CalcEMD(bad, sig2, opencv.DIST_L2, nil, false); err == nil {
+    t.Errorf("A signature with fewer features than weights should be rejected")
+  }
+}
+
+
+
+func TestContourScanner(t *testing.T) {
+  image    := opencv.LoadImage("../test_input.png", opencv.LOAD_IMAGE_GRAYSCALE)
+  if image == nil { t.Fatal("Could not load ../test_input.png") }
+  defer       image.Release()
+  contours, err := opencv.FindContours(image, opencv.RETR_LIST, opencv.CHAIN_APPROX_SIMPLE)
+  if err != nil { t.Fatal(err) }
+  // The scanner works in place, so give it a copy.
+  work     := image.Clone()
+  defer       work.Release()
+  scanner, err := opencv.StartFindContours(work, opencv.RETR_LIST, opencv.CHAIN_APPROX_SIMPLE)
+  if err != nil { t.Fatal(err) }
+  count    := 0
+  for {
+    contour, err := scanner.FindNextContour()
+    if err != nil { t.Fatal(err) }
+    if contour == nil { break }
+    count++
+  }
+  if count != len(contours) {
+    t.Errorf("Scanner should find the %d contours FindContours finds, got %d", len(contours), count)
+  }
+  scanner.EndFindContours()
+  if _, err := scanner.FindNextContour(); err == nil {
+    t.Errorf("FindNextContour after EndFindContours should fail")
+  }
+  if err := scanner.SubstituteContour(nil); err == nil {
+    t.Errorf("SubstituteContour after EndFindContours should fail")
+  }
+}
+
+
+
+func TestContourArea(t *testing.T) {
+  square  := &opencv.Contour{Points: []opencv.Point{{0, 0}, {10, 0}, {10, 10}, {0, 10}}}
+  if area, err := square.Area(); err != nil || (area != 100 && area != -100) {
+    t.Errorf("Area of a 10x10 square should be 100, got %v (%v)", area, err)
+  }
+  if length, err := square.ArcLength(true); err != nil || length != 40 {
+    t.Errorf("Perimeter of a 10x10 square should be 40, got %v (%v)", length, err)
+  }
+}
+
+
+
+func TestPolygon(t *testing.T) {
+  square := &opencv.Contour{Points: []opencv.Point{{0, 0}, {5, 0}, {10, 0}, {10, 10}, {0, 10}}}
+  approx, err := square.ApproxPoly(1, true)
+  if err != nil || len(approx.Points) != 4 {
+    t.Errorf("Square with a point on an edge should approximate to 4 points, got %v (%v)", approx, err)
+  }
+  if _, err := square.ApproxPoly(-1, true); err == nil {
+    t.Errorf("A negative epsilon should be rejected")
+  }
+  // A square with a notch cut into its right edge.
+  notched := &opencv.Contour{Points: []opencv.Point{{0, 0}, {10, 0}, {5, 5}, {10, 10}, {0, 10}}}
+  if convex, err := notched.IsConvex(); err != nil || convex {
+    t.Errorf("Notched square should not be convex (%v)", err)
+  }
+  hull, err := notched.ConvexHullIndices(opencv.CLOCKWISE)
+  if err != nil || len(hull) != 4 {
+    t.Fatalf("Convex hull of the notched square should have 4 points, got %v (%v)", hull, err)
+  }
+  defects, err := notched.ConvexityDefects(hull)
+  if err != nil || len(defects) != 1 || defects[0].DepthPoint != (opencv.Point{5, 5}) {
+    t.Errorf("Notched square should have one defect at 5, 5, got %v (%v)", defects, err)
+  }
+  if _, err := notched.ConvexityDefects([]int{0, 1, 5}); err == nil {
+    t.Errorf("Hull indices out of range should be rejected")
+  }
+  if inside, err := notched.PointPolygonTest(opencv.Point2D32f{2, 5}, false); err != nil || inside <= 0 {
+    t.Errorf("2, 5 should be inside the notched square, got %v (%v)", inside, err)
+  }
+  if inside, err := notched.PointPolygonTest(opencv.Point2D32f{8, 5}, false); err != nil || inside >= 0 {
+    t.Errorf("8, 5 should be in the notch, outside the square, got %v (%v)", inside, err)
+  }
+}
+
+
+
+func TestDistanceTypeString(t *testing.T) {
+  if name := opencv.DistanceType(opencv.DIST_L2).String(); name != "DIST_L2" {
+    t.Errorf("DIST_L2 should be named DIST_L2, got %v", name)
+  }
+  if name := opencv.DistanceType(42).String(); name != "DistanceType(42)" {
+    t.Errorf("Unknown distance types should be named by number, got %v", name)
+  }
+}
+
+
+
+func TestFit(t *testing.T) {
+  diagonal := []opencv.Point2D32f{{0, 0}, {1, 1}, {2, 2}, {3, 3}}
+  line, err := opencv.FitLine2D(diagonal, opencv.DIST_L2, 0, 0.01, 0.01)
+  if diff := float64(line.Vx - line.Vy); err != nil || diff > 1e-4 || diff < -1e-4 {
+    t.Errorf("Line through the diagonal should have equal direction components, got %v (%v)", line, err)
+  }
+  if _, err := opencv.FitLine2D(diagonal, opencv.DIST_C, 0, 0.01, 0.01); err == nil {
+    t.Errorf("FitLine2D should reject DIST_C")
+  }
+  corners := []opencv.Point2D32f{{0, 0}, {10, 0}, {10, 4}, {0, 4}}
+  box, err := opencv.MinAreaRect2(corners)
+  if area := box.Size.Width * box.Size.Height; err != nil || area < 39.9 || area > 40.1 {
+    t.Errorf("Minimal rectangle around a 10x4 rectangle should have area 40, got %v (%v)", box, err)
+  }
+  if _, err := opencv.MinAreaRect2(nil); err == nil {
+    t.Errorf("MinAreaRect2 without points should fail")
+  }
+  center, radius, err := opencv.MinEnclosingCircle(corners)
+  if err != nil || center.X < 4.99 || center.X > 5.01 || center.Y < 1.99 || center.Y > 2.01 ||
+     radius < 5.38 || radius > 5.40 {
+    t.Errorf("Circle around a 10x4 rectangle should be centered at 5, 2 with radius 5.39, got %v %v (%v)", center, radius, err)
+  }
+  if _, _, err := opencv.MinEnclosingCircle(nil); err == nil {
+    t.Errorf("MinEnclosingCircle without points should fail")
+  }
+}
+
+
+
+func TestBoxPoints(t *testing.T) {
+  box    := opencv.Box2D{Center: opencv.Point2D32f{X: 5, Y: 2}, Size: opencv.Size2D32f{Width: 10, Height: 4}, Angle: 30}
+  points := opencv.BoxPoints(box)
+  // Every corner of a 10x4 box lies half its diagonal, 5.39, from the center.
+  for _, point := range points {
+    dx, dy := float64(point.X - 5), float64(point.Y - 2)
+    if dist := math.Sqrt(dx * dx + dy * dy); dist < 5.38 || dist > 5.40 {
+      t.Errorf("Corners of a 10x4 box should be 5.39 from its center, got %v", points)
+      break
+    }
+  }
+  if diag := points[0].X + points[2].X; diag < 9.99 || diag > 10.01 {
+    t.Errorf("Opposite corners should be symmetric around the center, got %v", points)
+  }
+}
+
+
+
+func TestHoughLinesKeepsImage(t *testing.T) {
+  image    := opencv.LoadImage("../test_input.png", opencv.LOAD_IMAGE_GRAYSCALE)
+  if image == nil { t.Fatal("Could not load ../test_input.png") }
+  defer       image.Release()
+  before, err := image.Moments(true)
+  if err != nil { t.Fatal(err) }
+  params   := opencv.HoughProbabilisticParams{Rho: 1, Theta: math.Pi / 180, Threshold: 50, MinLength: 10, MaxGap: 2}
+  if _, err := opencv.HoughLinesP(image, params); err != nil { t.Fatal(err) }
+  after, err := image.Moments(true)
+  if err != nil { t.Fatal(err) }
+  if before != after {
+    t.Errorf("HoughLinesP should not modify the image")
+  }
+  if _, err := opencv.HoughLinesP(nil, params); err == nil {
+    t.Errorf("HoughLinesP without an image should fail")
+  }
+}
+
+
+
+func TestGoodFeaturesToTrack(t *testing.T) {
+  image    := opencv.LoadImage("../test_input.png", opencv.LOAD_IMAGE_GRAYSCALE)
+  if image == nil { t.Fatal("Could not load ../test_input.png") }
+  defer       image.Release()
+  params   := opencv.GoodFeaturesParams{MaxCorners: 10, QualityLevel: 0.01, MinDistance: 5, BlockSize: 3}
+  corners, err := opencv.GoodFeaturesToTrack(image, params, nil)
+  if err != nil { t.Fatal(err) }
+  if len(corners) > 10 {
+    t.Errorf("At most 10 corners should be returned, got %d", len(corners))
+  }
+  criteria := opencv.TermCriteria{Type: opencv.TERMCRIT_ITER | opencv.TERMCRIT_EPS, MaxIter: 20, Epsilon: 0.01}
+  refined, err := opencv.FindCornerSubPix(image, corners, opencv.Size{5, 5}, opencv.Size{-1, -1}, criteria)
+  if err != nil || len(refined) != len(corners) {
+    t.Errorf("Every corner should be refined, got %d of %d (%v)", len(refined), len(corners), err)
+  }
+  for _, corner := range refined {
+    if corner.X < 0 || corner.Y < 0 || corner.X >= float32(image.Width()) || corner.Y >= float32(image.Height()) {
+      t.Errorf("Refined corner %v lies outside of the image", corner)
+    }
+  }
+}
+
+
+
+func TestLKTracker(t *testing.T) {
+  gray     := opencv.LoadImage("../test_input.png", opencv.LOAD_IMAGE_GRAYSCALE)
+  if gray == nil { t.Fatal("Could not load ../test_input.png") }
+  defer       gray.Release()
+  color    := opencv.LoadImage("../test_input.png", opencv.LOAD_IMAGE_COLOR)
+  if color == nil { t.Fatal("Could not load ../test_input.png") }
+  defer       color.Release()
+  points   := []opencv.Point2D32f{{float32(gray.Width() / 2), float32(gray.Height() / 2)}}
+  criteria := opencv.TermCriteria{Type: opencv.TERMCRIT_ITER | opencv.TERMCRIT_EPS, MaxIter: 20, Epsilon: 0.03}
+  tracker  := opencv.NewLKTracker(opencv.Size{15, 15}, 2, criteria)
+  defer       tracker.Release()
+  next, found, _, err := tracker.Track(gray, points, nil)
+  if err != nil || !found[0] || next[0] != points[0] {
+    t.Fatalf("The first frame should return the points unchanged, got %v (%v)", next, err)
+  }
+  next, _, _, err = tracker.Track(gray, points, nil)
+  if err != nil { t.Fatal(err) }
+  if dx, dy := next[0].X - points[0].X, next[0].Y - points[0].Y; dx * dx + dy * dy > 0.01 {
+    t.Errorf("A point should stay put in an identical frame, got %v for %v", next, points)
+  }
+  // A different pyramid level must not reuse the pyramid built for the old one.
+  tracker.Level = 0
+  if _, _, _, err = tracker.Track(gray, points, nil); err != nil { t.Fatal(err) }
+  // A frame of the same size with other channels starts over.
+  next, found, _, err = tracker.Track(color, points, nil)
+  if err != nil || !found[0] || next[0] != points[0] {
+    t.Errorf("A frame with other channels should return the points unchanged, got %v (%v)", next, err)
+  }
+}
+
+
+
+func TestBMFlowSize(t *testing.T) {
+  size, err := opencv.BMFlowSize(opencv.Size{64, 48}, opencv.Size{8, 8}, opencv.Size{4, 4})
+  if err != nil || size != (opencv.Size{15, 11}) {
+    t.Errorf("8x8 blocks 4 apart in a 64x48 frame should give a 15x11 field, got %v (%v)", size, err)
+  }
+  if _, err := opencv.BMFlowSize(opencv.Size{64, 48}, opencv.Size{8, 8}, opencv.Size{0, 4}); err == nil {
+    t.Errorf("A zero shift should be rejected")
+  }
+  if _, err := opencv.BMFlowSize(opencv.Size{4, 4}, opencv.Size{8, 8}, opencv.Size{1, 1}); err == nil {
+    t.Errorf("A block larger than the frame should be rejected")
+  }
+}
+
+
+
+func TestFlowFieldAt(t *testing.T) {
+  flow := opencv.NewFlowField(4, 3)
+  if flow == nil { t.Fatal("Could not allocate flow field") }
+  defer flow.Release()
+  flow.At(3, 2)
+  defer func() {
+    if recover() == nil { t.Errorf("At outside of the flow field should panic") }
+  }()
+  flow.At(4, 0)
+}
+
+
+
+func TestChessboardObjectPoints(t *testing.T) {
+  points  := opencv.ChessboardObjectPoints(opencv.Size{3, 2}, 10)
+  if len(points) != 6 {
+    t.Fatalf("A 3x2 pattern should have 6 points, got %d", len(points))
+  }
+  last    := points[5]
+  if last.X != 20 || last.Y != 10 || last.Z != 0 {
+    t.Errorf("Last corner should be at 20, 10, 0, got %v", last)
+  }
 }
 
 
 
+func TestCalibrationInput(t *testing.T) {
+  object := [][]opencv.Point3D32f{opencv.ChessboardObjectPoints(opencv.Size{3, 2}, 10)}
+  image  := [][]opencv.Point2D32f{make([]opencv.Point2D32f, 5)}
+  if _, err := opencv.CalibrateCamera2(object, image, opencv.Size{640, 480}, nil, 0); err == nil {
+    t.Errorf("Views with different amounts of object and image points should be rejected")
+  }
+  if _, err := opencv.CalibrateCamera2(nil, nil, opencv.Size{640, 480}, nil, 0); err == nil {
+    t.Errorf("Calibration without views should be rejected")
+  }
+  if err := opencv.DrawChessboardCorners(nil, opencv.Size{3, 2}, make([]opencv.Point2D32f, 6), true); err == nil {
+    t.Errorf("Drawing corners without an image should fail")
+  }
+}
+
+
+
+func TestUndistorter(t *testing.T) {
+  intrinsics := opencv.CameraIntrinsics{Matrix: opencv.Mat3{{100, 0, 32}, {0, 100, 24}, {0, 0, 1}}}
+  undistorter, err := opencv.NewUndistorter(intrinsics, opencv.Size{64, 48})
+  if err != nil { t.Fatal(err) }
+  frame      := opencv.CreateImage(64, 48, opencv.IPL_DEPTH_8U, 1)
+  defer         frame.Release()
+  result     := opencv.CreateImage(64, 48, opencv.IPL_DEPTH_8U, 1)
+  defer         result.Release()
+  small      := opencv.CreateImage(32, 24, opencv.IPL_DEPTH_8U, 1)
+  defer         small.Release()
+  if err := undistorter.Undistort(frame, result); err != nil {
+    t.Errorf("Undistorting a frame of the right size should work, got %v", err)
+  }
+  if err := undistorter.Undistort(nil, result); err == nil {
+    t.Errorf("Undistorting without a frame should fail")
+  }
+  if err := undistorter.Undistort(small, result); err == nil {
+    t.Errorf("Undistorting a frame of the wrong size should fail")
+  }
+  undistorter.Release()
+  if err := undistorter.Undistort(frame, result); err == nil {
+    t.Errorf("Undistorting with a released undistorter should fail")
+  }
+}
+
+
+
+func TestRodriguesRoundTrip(t *testing.T) {
+  rvec    := opencv.Vec3{0.1, -0.2, 0.3}
+  rmat, err := rvec.RotationMatrix()
+  if err != nil { t.Fatal(err) }
+  back, err := rmat.RotationVector()
+  if err != nil { t.Fatal(err) }
+  for i := range rvec {
+    if diff := rvec[i] - back[i]; diff > 1e-9 || diff < -1e-9 {
+      t.Errorf("Rotation vector should survive a round trip, got %v for %v", back, rvec)
+    }
+  }
+}
+
+
+
+
+
+
+func TestProjectPoints2(t *testing.T) {
+  intrinsics := opencv.CameraIntrinsics{Matrix: opencv.Mat3{{100, 0, 32}, {0, 100, 24}, {0, 0, 1}}}
+  points     := []opencv.Point3D32f{{0, 0, 1}, {1, 0, 2}}
+  projected, _, err := opencv.ProjectPoints2(points, opencv.Vec3{}, opencv.Vec3{}, intrinsics, false)
+  if err != nil { t.Fatal(err) }
+  if projected[0] != (opencv.Point2D32f{32, 24}) || projected[1] != (opencv.Point2D32f{82, 24}) {
+    t.Errorf("Points should project to 32, 24 and 82, 24, got %v", projected)
+  }
+}
+
+
+
+func TestPOSITModel(t *testing.T) {
+  model, err := opencv.NewPOSITModel([]opencv.Point3D32f{{0, 0, 0}, {10, 0, 0}, {0, 10, 0}, {0, 0, 10}})
+  if err != nil { t.Fatal(err) }
+  // The model seen head-on from 100 units away with a focal length of 500.
+  image    := []opencv.Point2D32f{{0, 0}, {50, 0}, {0, 50}, {0, 0}}
+  criteria := opencv.TermCriteria{Type: opencv.TERMCRIT_ITER | opencv.TERMCRIT_EPS, MaxIter: 100, Epsilon: 1e-5}
+  _, translation, err := model.Estimate(image, 500, criteria)
+  if err != nil { t.Fatal(err) }
+  if math.Abs(translation[0]) > 1 || math.Abs(translation[1]) > 1 || math.Abs(translation[2] - 100) > 1 {
+    t.Errorf("Model should be 100 units in front of the camera, got %v", translation)
+  }
+  if _, _, err := model.Estimate(image[:3], 500, criteria); err == nil {
+    t.Errorf("Estimating from too few image points should fail")
+  }
+  model.Release()
+  if _, _, err := model.Estimate(image, 500, criteria); err == nil {
+    t.Errorf("Estimating with a released model should fail")
+  }
+  if _, err := opencv.NewPOSITModel(make([]opencv.Point3D32f, 3)); err == nil {
+    t.Errorf("A model of three points should be rejected")
+  }
+}
+
+
+
+func TestFindHomography(t *testing.T) {
+  src := []opencv.Point2D32f{{0, 0}, {10, 0}, {10, 10}, {0, 10}, {5, 5}}
+  dst := make([]opencv.Point2D32f, len(src))
+  for i, point := range src { dst[i] = opencv.Point2D32f{point.X + 5, point.Y + 3} }
+  homography, inliers, err := opencv.FindHomography(src, dst, 0, 0)
+  if err != nil { t.Fatal(err) }
+  scale := homography[2][2]
+  if math.Abs(homography[0][2] / scale - 5) > 1e-3 || math.Abs(homography[1][2] / scale - 3) > 1e-3 {
+    t.Errorf("Homography of a shift by 5, 3 should translate by 5, 3, got %v", homography)
+  }
+  if len(inliers) != len(src) {
+    t.Errorf("There should be an inlier flag per point pair, got %v", inliers)
+  }
+  if _, _, err := opencv.FindHomography(src[:3], dst[:3], 0, 0); err == nil {
+    t.Errorf("A homography from three pairs should be rejected")
+  }
+}
+
+
+
+func TestHomogeneousPoints(t *testing.T) {
+  homogeneous, err := opencv.ConvertPointsToHomogeneous([]opencv.Point2D32f{{2, 4}})
+  if err != nil || len(homogeneous) != 1 || homogeneous[0] != (opencv.Point3D32f{2, 4, 1}) {
+    t.Errorf("2, 4 should become 2, 4, 1, got %v (%v)", homogeneous, err)
+  }
+  points, err := opencv.ConvertPointsFromHomogeneous([]opencv.Point3D32f{{4, 8, 2}})
+  if err != nil || len(points) != 1 || points[0] != (opencv.Point2D32f{2, 4}) {
+    t.Errorf("4, 8, 2 should become 2, 4, got %v (%v)", points, err)
+  }
+}
+
+
+
+func TestWritePLY(t *testing.T) {
+  // Any finite disparity will do: this Q puts every point at 1, 2, 3.
+  intrinsics := opencv.CameraIntrinsics{Matrix: opencv.Mat3{{100, 0, 2}, {0, 100, 1}, {0, 0, 1}}}
+  disparity, other, err := opencv.InitUndistortMap(intrinsics, opencv.Size{4, 3})
+  if err != nil { t.Fatal(err) }
+  defer disparity.Release()
+  defer other.Release()
+  points := opencv.CreateImage(4, 3, opencv.IPL_DEPTH_32F, 3)
+  defer     points.Release()
+  q      := opencv.Mat4{{0, 0, 0, 1}, {0, 0, 0, 2}, {0, 0, 0, 3}, {0, 0, 0, 1}}
+  if err := opencv.ReprojectImageTo3D(disparity, points, q, false); err != nil { t.Fatal(err) }
+  var out bytes.Buffer
+  if err := opencv.WritePLY(&out, points, nil); err != nil { t.Fatal(err) }
+  ply := out.String()
+  if !strings.HasPrefix(ply, "ply\nformat ascii 1.0\nelement vertex 12\n") {
+    t.Errorf("PLY header should announce 12 vertices, got %q", ply)
+  }
+  if !strings.HasSuffix(ply, "end_header\n" + strings.Repeat("1 2 3\n", 12)) {
+    t.Errorf("PLY should hold 12 vertices at 1, 2, 3, got %q", ply)
+  }
+  if err := opencv.WritePLY(&out, disparity, nil); err == nil {
+    t.Errorf("WritePLY should reject a single channel image")
+  }
+}
+
+
+
+func TestStereoBM(t *testing.T) {
+  image    := opencv.LoadImage("../test_input.png", opencv.LOAD_IMAGE_GRAYSCALE)
+  if image == nil { t.Fatal("Could not load ../test_input.png") }
+  defer       image.Release()
+  matcher, err := opencv.NewStereoBM(opencv.STEREO_BM_BASIC, 16)
+  if err != nil { t.Fatal(err) }
+  disparity, err := matcher.Compute(image, image)
+  if err != nil { t.Fatal(err) }
+  defer disparity.Release()
+  if disparity.Width() != image.Width() || disparity.Height() != image.Height() ||
+     disparity.Depth() != opencv.IPL_DEPTH_16S {
+    t.Errorf("Disparity should be a 16-bit signed image of the size of the input")
+  }
+  display, err := opencv.NormalizeDisparity(disparity)
+  if err != nil || display.Depth() != opencv.IPL_DEPTH_8U {
+    t.Errorf("Normalized disparity should be an 8-bit image (%v)", err)
+  }
+  display.Release()
+  matcher.Release()
+  if _, err := matcher.Compute(image, image); err == nil {
+    t.Errorf("Computing with a released block matcher should fail")
+  }
+}
+
+
+
+func TestFindStereoCorrespondence(t *testing.T) {
+  image    := opencv.LoadImage("../test_input.png", opencv.LOAD_IMAGE_GRAYSCALE)
+  if image == nil { t.Fatal("Could not load ../test_input.png") }
+  defer       image.Release()
+  params   := opencv.DefaultBirchfieldParams(8)
+  if params.MaxDisparity != 8 || params.Occlusion != opencv.IDP_BIRCHFIELD_PARAM1 {
+    t.Errorf("Default parameters should use the OpenCV defaults, got %v", params)
+  }
+  disparity, err := opencv.FindStereoCorrespondence(image, image, params)
+  if err != nil { t.Fatal(err) }
+  defer disparity.Release()
+  if disparity.Width() != image.Width() || disparity.Height() != image.Height() {
+    t.Errorf("Disparity should have the size of the input")
+  }
+  params.MaxDisparity = 0
+  if _, err := opencv.FindStereoCorrespondence(image, image, params); err == nil {
+    t.Errorf("A MaxDisparity of 0 should be rejected")
+  }
+}
+
+
+
+func TestKalmanFilter(t *testing.T) {
+  kalman, err := opencv.NewKalmanFilter(2, 1, 0)
+  if err != nil {
+    t.Fatalf("Could not create Kalman filter: %v", err)
+  }
+  defer kalman.Release()
+  kalman.SetTransitionMatrix([][]float64{{1, 1}, {0, 1}})
+  kalman.SetMeasurementMatrix([][]float64{{1, 0}})
+  kalman.SetState([]float64{0, 1})
+  predicted, err := kalman.Predict(nil)
+  if err != nil || predicted[0] != 1 || predicted[1] != 1 {
+    t.Errorf("Constant velocity prediction should be [1 1], got %v (%v)", predicted, err)
+  }
+  if err := kalman.SetMeasurementMatrix([][]float64{{1, 0, 0}}); err == nil {
+    t.Errorf("Setting a matrix of the wrong size should fail")
+  }
+}
+
+
+
+func TestBackgroundModels(t *testing.T) {
+  frame := opencv.LoadImage("../test_input.png", opencv.LOAD_IMAGE_COLOR)
+  if frame == nil {
+    t.Fatal("Could not load ../test_input.png")
+  }
+  defer frame.Release()
+  mog, err := opencv.NewMOGModel(frame, opencv.DefaultMOGParams())
+  if err != nil {
+    t.Fatalf("Could not create MOG model: %v", err)
+  }
+  defer mog.Release()
+  // The masks are copies, releasing them must leave the model intact.
+  for i := 0; i < 2; i++ {
+    mask, err := mog.Update(frame)
+    if err != nil {
+      t.Fatalf("MOG update failed: %v", err)
+    }
+    if mask.Width() != frame.Width() || mask.Height() != frame.Height() {
+      t.Errorf("Mask should be %dx%d, got %dx%d", frame.Width(), frame.Height(), mask.Width(), mask.Height())
+    }
+    mask.Release()
+  }
+  if background := mog.Background(); background != nil {
+    background.Release()
+  }
+
+  codebook, err := opencv.NewCodebookModel(opencv.DefaultCodebookParams())
+  if err != nil {
+    t.Fatalf("Could not create codebook model: %v", err)
+  }
+  defer codebook.Release()
+  gray := opencv.CreateImage(frame.Width(), frame.Height(), opencv.IPL_DEPTH_8U, 1)
+  defer gray.Release()
+  if _, err := codebook.Update(gray); err == nil {
+    t.Errorf("The codebook model should reject single channel frames")
+  }
+  for i := 0; i < 2; i++ {
+    mask, err := codebook.Update(frame)
+    if err != nil {
+      t.Fatalf("Codebook update failed: %v", err)
+    }
+    mask.Release()
+  }
+}
+
+
+
+func TestCascadeLoadAndRelease(t *testing.T) {
+  if _, err := opencv.LoadCascade("does-not-exist.xml"); err == nil {
+    t.Errorf("Loading a missing cascade should fail")
+  }
+  if _, err := opencv.LoadCascadeBytes([]byte("<opencv_storage></opencv_storage>")); err == nil {
+    t.Errorf("Loading a file without a cascade should fail")
+  }
+  image   := opencv.CreateImage(8, 8, opencv.IPL_DEPTH_8U, 1)
+  defer      image.Release()
+  cascade := &opencv.Cascade{}
+  cascade.Release()
+  if size := cascade.Size(); size != (opencv.Size{}) {
+    t.Errorf("A released cascade should have an empty size, got %v", size)
+  }
+  if _, err := cascade.Detect(image, opencv.DefaultDetectOptions); err == nil {
+    t.Errorf("Detecting with a released cascade should fail")
+  }
+  if model := cascade.Model(); model != nil {
+    t.Errorf("A released cascade should have no model, got %v", model)
+  }
+}
+
+
+
+// haarClassifier returns a weak classifier with a node per branch pair.
+func haarClassifier(left, right []int) opencv.HaarClassifier {
+  count := len(left)
+  return opencv.HaarClassifier{
+    Features:   make([]opencv.HaarFeature, count),
+    Thresholds: make([]float32, count),
+    Left:       left,
+    Right:      right,
+    Alpha:      make([]float32, count + 1),
+  }
+}
+
+
+
+func TestCascadeModelCheck(t *testing.T) {
+  stage := func(next, child, parent int, classifiers ...opencv.HaarClassifier) opencv.HaarStage {
+    return opencv.HaarStage{Next: next, Child: child, Parent: parent, Classifiers: classifiers}
+  }
+  inconsistent := haarClassifier([]int{0}, []int{-1})
+  inconsistent.Alpha = inconsistent.Alpha[:1]
+  tests := []struct {
+    name   string
+    stages []opencv.HaarStage
+  }{
+    {"no stages", nil},
+    {"link out of range", []opencv.HaarStage{stage(5, -1, -1)}},
+    {"parent after child", []opencv.HaarStage{stage(-1, -1, 1), stage(-1, -1, -1)}},
+    {"child with another parent", []opencv.HaarStage{stage(-1, 1, -1), stage(-1, -1, -1)}},
+    {"next with another parent", []opencv.HaarStage{stage(1, -1, -1), stage(-1, -1, 0)}},
+    {"next to itself", []opencv.HaarStage{stage(0, -1, -1)}},
+    {"circle of next links", []opencv.HaarStage{stage(1, -1, -1), stage(0, -1, -1)}},
+    {"inconsistent classifier", []opencv.HaarStage{stage(-1, -1, -1, inconsistent)}},
+    {"branch to itself", []opencv.HaarStage{stage(-1, -1, -1, haarClassifier([]int{1, 1}, []int{0, -1}))}},
+    {"branch back", []opencv.HaarStage{stage(-1, -1, -1, haarClassifier([]int{1, 2, 1}, []int{0, -1, -2}))}},
+    {"leaf out of range", []opencv.HaarStage{stage(-1, -1, -1, haarClassifier([]int{1, -1}, []int{0, -3}))}},
+  }
+  for _, test := range tests {
+    model := &opencv.CascadeModel{WindowSize: opencv.Size{20, 20}, Stages: test.stages}
+    if cascade, err := model.Cascade(); err == nil {
+      cascade.Release()
+      t.Errorf("Cascade model with %s should be rejected", test.name)
+    }
+  }
+}
+
+
+
+func TestCascadeModelKeepStages(t *testing.T) {
+  model := &opencv.CascadeModel{Stages: []opencv.HaarStage{
+    {Next: 1, Child: 2, Parent: -1},
+    {Next: 2, Child: -1, Parent: 0},
+    {Next: -1, Child: -1, Parent: 1},
+  }}
+  model.KeepStages(2)
+  if len(model.Stages) != 2 {
+    t.Fatalf("Model should keep 2 stages, got %d", len(model.Stages))
+  }
+  if model.Stages[0].Next != 1 || model.Stages[1].Parent != 0 {
+    t.Errorf("Links between kept stages should be preserved, got %v", model.Stages)
+  }
+  if model.Stages[0].Child != -1 || model.Stages[1].Next != -1 {
+    t.Errorf("Links to dropped stages should be cleared, got %v", model.Stages)
+  }
+}
+
+
+
+func TestParticleFilter(t *testing.T) {
+  if _, err := opencv.NewParticleFilter(0, 1, 10); err == nil {
+    t.Errorf("A particle filter without states should be rejected")
+  }
+  filter, err := opencv.NewParticleFilter(2, 2, 100)
+  if err != nil {
+    t.Fatalf("Could not create particle filter: %v", err)
+  }
+  defer filter.Release()
+  if err := filter.SetDynamics([][]float64{{1, 0}, {0, 1}}); err != nil {
+    t.Fatalf("Could not set dynamics: %v", err)
+  }
+  if err := filter.SetDynamics([][]float64{{1, 0, 0}}); err == nil {
+    t.Errorf("A dynamics matrix of the wrong size should be rejected")
+  }
+  if err := filter.InitSampleSet([]float64{0, 0}, []float64{10, 10}); err != nil {
+    t.Fatalf("Could not initialize samples: %v", err)
+  }
+  samples := filter.Samples()
+  if len(samples) != 100 {
+    t.Fatalf("Expected 100 samples, got %d", len(samples))
+  }
+  for _, sample := range samples {
+    if sample[0] < 0 || sample[0] > 10 || sample[1] < 0 || sample[1] > 10 {
+      t.Errorf("Sample %v lies outside of the initial bounds", sample)
+      break
+    }
+  }
+  if err := filter.SetConfidences(make([]float64, 99)); err == nil {
+    t.Errorf("A confidence count that does not match the samples should be rejected")
+  }
+  if err := filter.Weigh(func(sample []float64) float64 { return 1 }); err != nil {
+    t.Fatalf("Could not weigh samples: %v", err)
+  }
+  // The estimate is the weighted mean of samples within the bounds.
+  state, err := filter.Update()
+  if err != nil || len(state) != 2 || state[0] < 0 || state[0] > 10 || state[1] < 0 || state[1] > 10 {
+    t.Errorf("Expected a state within the bounds, got %v (%v)", state, err)
+  }
+  filter.Release()
+  if err := filter.SetConfidences(make([]float64, 100)); err == nil {
+    t.Errorf("Setting confidences on a released filter should fail")
+  }
+  if _, err := filter.Update(); err == nil {
+    t.Errorf("Updating a released filter should fail")
+  }
+}
+
+
+
+func TestCamShiftTracker(t *testing.T) {
+  criteria := opencv.TermCriteria{Type: opencv.TERMCRIT_ITER | opencv.TERMCRIT_EPS, MaxIter: 10, Epsilon: 1}
+  if _, err := opencv.MeanShift(nil, opencv.Rect{0, 0, 10, 10}, criteria); err == nil {
+    t.Errorf("MeanShift without a probability image should fail")
+  }
+  gray := opencv.LoadImage("../test_input.png", opencv.LOAD_IMAGE_GRAYSCALE)
+  if gray == nil {
+    t.Fatal("Could not load ../test_input.png")
+  }
+  defer gray.Release()
+  comp, err := opencv.MeanShift(gray, opencv.Rect{10, 10, 20, 20}, criteria)
+  if err != nil || comp.Rect.Width != 20 || comp.Rect.Height != 20 {
+    t.Errorf("MeanShift should keep the window size, got %v (%v)", comp.Rect, err)
+  }
+
+  frame := opencv.LoadImage("../test_input.png", opencv.LOAD_IMAGE_COLOR)
+  defer frame.Release()
+  tracker, err := opencv.NewCamShiftTracker(16, criteria)
+  if err != nil {
+    t.Fatalf("Could not create tracker: %v", err)
+  }
+  defer tracker.Release()
+  if _, _, err := tracker.Track(frame); err == nil {
+    t.Errorf("Tracking without a target should fail")
+  }
+  if err := tracker.SetTarget(frame, opencv.Rect{10, 10, 0, 0}); err == nil {
+    t.Errorf("An empty target region should be rejected")
+  }
+  if err := tracker.SetTarget(frame, opencv.Rect{10, 10, 40, 40}); err != nil {
+    t.Fatalf("Could not set target: %v", err)
+  }
+  if _, _, err := tracker.Track(frame); err != nil {
+    t.Errorf("Tracking failed: %v", err)
+  }
+  if tracker.Window.Width <= 0 || tracker.Window.Height <= 0 {
+    t.Errorf("Tracking should keep a non-empty window, got %v", tracker.Window)
+  }
+  tracker.Release()
+  if err := tracker.SetTarget(frame, opencv.Rect{10, 10, 40, 40}); err == nil {
+    t.Errorf("Setting the target of a released tracker should fail")
+  }
+}
+
+
+
+func TestMotionHistory(t *testing.T) {
+  silhouette := opencv.LoadImage("../test_input.png", opencv.LOAD_IMAGE_GRAYSCALE)
+  if silhouette == nil {
+    t.Fatal("Could not load ../test_input.png")
+  }
+  defer silhouette.Release()
+  size    := opencv.Size{silhouette.Width(), silhouette.Height()}
+  history, err := opencv.NewMotionHistory(size, 1, 0.05, 0.5)
+  if err != nil {
+    t.Fatalf("Could not create motion history: %v", err)
+  }
+  defer history.Release()
+  for i, timestamp := range []float64{0.1, 0.2} {
+    if err := history.Update(silhouette, timestamp); err != nil {
+      t.Fatalf("Update %d failed: %v", i, err)
+    }
+  }
+  if _, err := history.GlobalOrientation(); err != nil {
+    t.Errorf("GlobalOrientation failed: %v", err)
+  }
+  if _, err := history.Segment(); err != nil {
+    t.Errorf("Segment failed: %v", err)
+  }
+  small := opencv.CreateImage(size.Width / 2, size.Height / 2, opencv.IPL_DEPTH_8U, 1)
+  defer small.Release()
+  if err := history.Update(small, 0.3); err == nil {
+    t.Errorf("A silhouette of the wrong size should be rejected")
+  }
+  history.Release()
+  if err := history.Update(silhouette, 0.4); err == nil {
+    t.Errorf("Updating a released motion history should fail")
+  }
+  if _, err := history.Segment(); err == nil {
+    t.Errorf("Segmenting a released motion history should fail")
+  }
+}
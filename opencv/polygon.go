@@ -0,0 +1,155 @@
+/*
+Polygon approximation, convex hulls and convexity defects of contours.
+*/
+package opencv
+
+// #include <opencv/cv.h>
+import "C"
+import "errors"
+import "unsafe"
+
+// ConvexityDefect is a part of a contour that deviates from its convex hull.
+// The defect lies between the hull points Start and End, DepthPoint is the
+// point of the defect farthest from the hull and Depth its distance to it.
+type ConvexityDefect struct {
+  Start      Point
+  End        Point
+  DepthPoint Point
+  Depth      float32
+}
+
+// ApproxPoly approximates the contour with a polygon with less vertices
+// using the Douglas-Peucker algorithm (POLY_APPROX_DP), so that the
+// distance between the polygon and the contour is at most epsilon.
+// closed tells whether the contour should be treated as a closed curve.
+// The returned contour has no links to other contours.
+func (self * Contour) ApproxPoly(epsilon float64, closed bool) (* Contour, error) {
+  if epsilon < 0 { return nil, errors.New("opencv: ApproxPoly needs a non-negative epsilon") }
+  cmat := newPointMat(self.Points) ; defer releaseCMat(cmat)
+  if cmat == nil { return &Contour{Hole: self.Hole}, nil }
+  cstorage := newStorage()
+  defer releaseStorage(cstorage)
+  cseq := C.cvApproxPoly(carr(cmat), C.sizeof_CvContour, cstorage,
+                         POLY_APPROX_DP, C.double(epsilon), cbool(closed))
+  if err := lastError(); err != nil { return nil, err }
+  if cseq == nil { return nil, errors.New("opencv: could not approximate contour") }
+  return &Contour{Points: seqPoints(cseq), Hole: self.Hole}, nil
+}
+
+// ApproxPolyTree approximates a whole contour hierarchy, as returned by
+// FindContours, with closed polygons. The returned hierarchy has the same
+// structure as the original one.
+func ApproxPolyTree(contours []* Contour, epsilon float64) ([]* Contour, error) {
+  return approxPolyLevel(contours, nil, epsilon)
+}
+
+// approxPolyLevel approximates one level of a contour hierarchy and all the
+// levels below it.
+func approxPolyLevel(contours []* Contour, parent * Contour, epsilon float64) ([]* Contour, error) {
+  var result []* Contour
+  var prev * Contour
+  for _, contour := range contours {
+    approx, err    := contour.ApproxPoly(epsilon, true)
+    if err != nil { return nil, err }
+    approx.Parent   = parent
+    approx.Prev     = prev
+    if prev != nil { prev.Next = approx }
+    approx.Children, err = approxPolyLevel(contour.Children, approx, epsilon)
+    if err != nil { return nil, err }
+    result          = append(result, approx)
+    prev            = approx
+  }
+  return result, nil
+}
+
+// convexHull calculates the convex hull of the contour into a matrix of the
+// given type, CV_32SC1 for indices or CV_32SC2 for points.
+func (self * Contour) convexHull(orientation int, hulltype C.int) (* C.CvMat, error) {
+  cmat := newPointMat(self.Points) ; defer releaseCMat(cmat)
+  if cmat == nil { return nil, nil }
+  chull := C.cvCreateMat(1, C.int(len(self.Points)), hulltype)
+  C.cvConvexHull2(carr(cmat), unsafe.Pointer(chull), C.int(orientation),
+                  cbool(hulltype == C.CV_32SC2))
+  if err := lastError(); err != nil {
+    releaseCMat(chull)
+    return nil, err
+  }
+  // cvConvexHull2 stores the amount of hull points in the matrix width.
+  return chull, nil
+}
+
+// ConvexHull returns the points of the convex hull of the contour, ordered
+// CLOCKWISE or COUNTER_CLOCKWISE according to orientation.
+func (self * Contour) ConvexHull(orientation int) ([]Point, error) {
+  chull, err := self.convexHull(orientation, C.CV_32SC2)
+  if err != nil || chull == nil { return nil, err }
+  defer releaseCMat(chull)
+  cdata := (* [1 << 26]C.CvPoint)(matData(chull))
+  hull  := make([]Point, int(chull.cols))
+  for i := range hull { hull[i] = wrapPoint(cdata[i]) }
+  return hull, nil
+}
+
+// ConvexHullIndices returns the convex hull of the contour as indices into
+// its Points, ordered CLOCKWISE or COUNTER_CLOCKWISE according to
+// orientation.
+func (self * Contour) ConvexHullIndices(orientation int) ([]int, error) {
+  chull, err := self.convexHull(orientation, C.CV_32SC1)
+  if err != nil || chull == nil { return nil, err }
+  defer releaseCMat(chull)
+  cdata := (* [1 << 26]C.int)(matData(chull))
+  hull  := make([]int, int(chull.cols))
+  for i := range hull { hull[i] = int(cdata[i]) }
+  return hull, nil
+}
+
+// IsConvex returns true if the contour is convex and has no
+// self-intersections.
+func (self * Contour) IsConvex() (bool, error) {
+  cmat := newPointMat(self.Points) ; defer releaseCMat(cmat)
+  if cmat == nil { return false, nil }
+  cconvex := C.cvCheckContourConvexity(carr(cmat))
+  if err := lastError(); err != nil { return false, err }
+  return cconvex != 0, nil
+}
+
+// ConvexityDefects returns the convexity defects of the contour with regard
+// to its convex hull, given as indices like ConvexHullIndices returns them.
+func (self * Contour) ConvexityDefects(hull []int) ([]ConvexityDefect, error) {
+  if len(hull) < 3 { return nil, nil }
+  for _, index := range hull {
+    if index < 0 || index >= len(self.Points) {
+      return nil, errors.New("opencv: convex hull index out of range of the contour")
+    }
+  }
+  cmat := newPointMat(self.Points) ; defer releaseCMat(cmat)
+  chull := C.cvCreateMat(1, C.int(len(hull)), C.CV_32SC1)
+  defer releaseCMat(chull)
+  cdata := (* [1 << 26]C.int)(matData(chull))
+  for i, index := range hull { cdata[i] = C.int(index) }
+
+  cstorage := newStorage()
+  defer releaseStorage(cstorage)
+  cseq    := C.cvConvexityDefects(carr(cmat), carr(chull), cstorage)
+  if err := lastError(); err != nil { return nil, err }
+  if cseq == nil { return nil, nil }
+  defects := make([]ConvexityDefect, int(cseq.total))
+  for i := range defects {
+    cdefect   := (* C.CvConvexityDefect)(seqElem(cseq, i))
+    defects[i] = ConvexityDefect{wrapPoint(*cdefect.start), wrapPoint(*cdefect.end),
+                                 wrapPoint(*cdefect.depth_point), float32(cdefect.depth)}
+  }
+  return defects, nil
+}
+
+// PointPolygonTest tests whether the point lies inside the contour. If
+// measure is false, the result is positive inside, negative outside and 0
+// on an edge of the contour. If measure is true, the result is the signed
+// distance between the point and the nearest contour edge.
+func (self * Contour) PointPolygonTest(point Point2D32f, measure bool) (float64, error) {
+  cmat := newPointMat(self.Points) ; defer releaseCMat(cmat)
+  if cmat == nil { return -1, nil }
+  cresult := C.cvPointPolygonTest(carr(cmat), point.cpoint(), cbool(measure))
+  if err := lastError(); err != nil { return 0, err }
+  return float64(cresult), nil
+}
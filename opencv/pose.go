@@ -0,0 +1,124 @@
+/*
+Pose estimation, point projection and Rodrigues rotation conversions.
+*/
+package opencv
+
+// #include <opencv/cv.h>
+import "C"
+import "errors"
+
+// ProjectionJacobians are the derivatives of the projected image points
+// with regard to the parameters of ProjectPoints2. Every matrix has two rows
+// per point, for the x and y coordinate, and one column per parameter.
+type ProjectionJacobians struct {
+  // Rotation holds the derivatives by the 3 components of the rotation vector.
+  Rotation    [][]float64
+  // Translation holds the derivatives by the 3 components of the translation.
+  Translation [][]float64
+  // Focal holds the derivatives by the focal lengths fx and fy.
+  Focal       [][]float64
+  // Center holds the derivatives by the principal point cx and cy.
+  Center      [][]float64
+  // Distortion holds the derivatives by the 5 distortion coefficients.
+  Distortion  [][]float64
+}
+
+// RodriguesV2M converts a rotation vector, whose direction is the rotation
+// axis and whose length is the rotation angle, to a rotation matrix
+// (RODRIGUES_V2M). jacobian holds the derivatives of the 9 matrix elements
+// by the 3 vector components.
+func RodriguesV2M(rvec Vec3) (rmat Mat3, jacobian [3][9]float64, err error) {
+  csrc := rvec.cmat()                    ; defer releaseCMat(csrc)
+  cdst := newCMat(3, 3, CV_64F, nil)     ; defer releaseCMat(cdst)
+  cjac := newCMat(3, 9, CV_64F, nil)     ; defer releaseCMat(cjac)
+  C.cvRodrigues2(csrc, cdst, cjac)
+  if err = lastError(); err != nil { return rmat, jacobian, err }
+  for i, row := range cmatData(cjac) { copy(jacobian[i][:], row) }
+  return wrapMat3(cdst), jacobian, nil
+}
+
+// RodriguesM2V converts a rotation matrix to a rotation vector
+// (RODRIGUES_M2V). jacobian holds the derivatives of the 3 vector components
+// by the 9 matrix elements.
+func RodriguesM2V(rmat Mat3) (rvec Vec3, jacobian [9][3]float64, err error) {
+  csrc := rmat.cmat()                    ; defer releaseCMat(csrc)
+  cdst := newCMat(3, 1, CV_64F, nil)     ; defer releaseCMat(cdst)
+  cjac := newCMat(9, 3, CV_64F, nil)     ; defer releaseCMat(cjac)
+  C.cvRodrigues2(csrc, cdst, cjac)
+  if err = lastError(); err != nil { return rvec, jacobian, err }
+  for i, row := range cmatData(cjac) { copy(jacobian[i][:], row) }
+  return wrapVec3(cdst), jacobian, nil
+}
+
+// RotationMatrix returns the rotation matrix of the rotation vector.
+func (self Vec3) RotationMatrix() (Mat3, error) {
+  rmat, _, err := RodriguesV2M(self)
+  return rmat, err
+}
+
+// RotationVector returns the rotation vector of the rotation matrix.
+func (self Mat3) RotationVector() (Vec3, error) {
+  rvec, _, err := RodriguesM2V(self)
+  return rvec, err
+}
+
+// FindExtrinsicCameraParams2 estimates the pose of an object, given the
+// positions of its 3D points in object space and of their projections in
+// the image of a camera with the given intrinsics. Returns the rotation
+// vector and the translation of the object relative to the camera.
+func FindExtrinsicCameraParams2(objectPoints []Point3D32f, imagePoints []Point2D32f,
+                                intrinsics CameraIntrinsics) (rotation, translation Vec3, err error) {
+  if len(objectPoints) < 4 || len(objectPoints) != len(imagePoints) {
+    return rotation, translation, errors.New("opencv: pose estimation needs the same amount of at least four object and image points")
+  }
+  cobject        := newPoint3D32fMat(objectPoints) ; defer releaseCMat(cobject)
+  cimage         := newPoint2D32fMat(imagePoints)  ; defer releaseCMat(cimage)
+  cmatrix, cdist := intrinsics.cmats()
+  defer releaseCMat(cmatrix)
+  defer releaseCMat(cdist)
+  crot           := newCMat(3, 1, CV_64F, nil)     ; defer releaseCMat(crot)
+  ctrans         := newCMat(3, 1, CV_64F, nil)     ; defer releaseCMat(ctrans)
+  C.cvFindExtrinsicCameraParams2(cobject, cimage, cmatrix, cdist, crot, ctrans)
+  if err = lastError(); err != nil { return rotation, translation, err }
+  return wrapVec3(crot), wrapVec3(ctrans), nil
+}
+
+// ProjectPoints2 projects 3D object points to the image of a camera with the
+// given intrinsics, for an object with the given rotation vector and
+// translation. If wantJacobians is true, the derivatives of the image points
+// by all parameters are returned as well, otherwise jacobians is nil.
+func ProjectPoints2(objectPoints []Point3D32f, rotation, translation Vec3, intrinsics CameraIntrinsics,
+                    wantJacobians bool) (imagePoints []Point2D32f, jacobians * ProjectionJacobians, err error) {
+  count := len(objectPoints)
+  if count == 0 { return nil, nil, nil }
+  cobject        := newPoint3D32fMat(objectPoints) ; defer releaseCMat(cobject)
+  cimage         := C.cvCreateMat(1, C.int(count), C.CV_32FC2)
+  defer releaseCMat(cimage)
+  crot           := rotation.cmat()                ; defer releaseCMat(crot)
+  ctrans         := translation.cmat()             ; defer releaseCMat(ctrans)
+  cmatrix, cdist := intrinsics.cmats()
+  defer releaseCMat(cmatrix)
+  defer releaseCMat(cdist)
+
+  var cdrot, cdtrans, cdfocal, cdcenter, cddist * C.CvMat
+  if wantJacobians {
+    cdrot    = newCMat(2 * count, 3, CV_64F, nil) ; defer releaseCMat(cdrot)
+    cdtrans  = newCMat(2 * count, 3, CV_64F, nil) ; defer releaseCMat(cdtrans)
+    cdfocal  = newCMat(2 * count, 2, CV_64F, nil) ; defer releaseCMat(cdfocal)
+    cdcenter = newCMat(2 * count, 2, CV_64F, nil) ; defer releaseCMat(cdcenter)
+    cddist   = newCMat(2 * count, len(intrinsics.Distortion), CV_64F, nil)
+    defer releaseCMat(cddist)
+  }
+  C.cvProjectPoints2(cobject, crot, ctrans, cmatrix, cdist, cimage,
+                     cdrot, cdtrans, cdfocal, cdcenter, cddist, 0)
+  if err = lastError(); err != nil { return nil, nil, err }
+
+  cdata      := (* [1 << 26]C.CvPoint2D32f)(matData(cimage))
+  imagePoints = make([]Point2D32f, count)
+  for i := range imagePoints { imagePoints[i] = wrapPoint2D32f(cdata[i]) }
+  if wantJacobians {
+    jacobians = &ProjectionJacobians{cmatData(cdrot), cmatData(cdtrans), cmatData(cdfocal),
+                                     cmatData(cdcenter), cmatData(cddist)}
+  }
+  return imagePoints, jacobians, nil
+}
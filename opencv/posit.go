@@ -0,0 +1,68 @@
+/*
+POSIT pose estimation of known 3D models.
+*/
+package opencv
+
+// #include <opencv/cv.h>
+import "C"
+import "errors"
+import "runtime"
+
+// POSITModel is a 3D model whose pose can be estimated with the POSIT
+// algorithm. The native object is released automatically when the model is
+// garbage collected, or explicitly with Release.
+type POSITModel struct {
+  cobject * C.CvPOSITObject
+  count   int
+}
+
+// NewPOSITModel creates a POSIT model from at least four non-coplanar
+// points. The first point is the reference point of the model, the pose is
+// estimated relative to it.
+func NewPOSITModel(points []Point3D32f) (* POSITModel, error) {
+  if len(points) < 4 { return nil, errors.New("opencv: a POSIT model needs at least four points") }
+  cpoints := make([]C.CvPoint3D32f, len(points))
+  for i, point := range points {
+    cpoints[i] = C.cvPoint3D32f(C.double(point.X), C.double(point.Y), C.double(point.Z))
+  }
+  cobject := C.cvCreatePOSITObject(&cpoints[0], C.int(len(points)))
+  if err := lastError(); err != nil { return nil, err }
+  if cobject == nil { return nil, errors.New("opencv: could not create POSIT model") }
+  model := &POSITModel{cobject, len(points)}
+  runtime.SetFinalizer(model, (* POSITModel).Release)
+  return model, nil
+}
+
+// Release releases the native POSIT object. The model may not be used
+// afterwards.
+func (self * POSITModel) Release() {
+  if self.cobject != nil {
+    C.cvReleasePOSITObject(&self.cobject)
+  }
+  self.cobject = nil
+  runtime.SetFinalizer(self, nil)
+}
+
+// Estimate estimates the pose of the model from the projections of its
+// points, in the same order, in the image of a camera with the given focal
+// length in pixels. Image points are relative to the image center. The
+// iterations stop according to criteria. Returns the rotation matrix and
+// the translation of the model relative to the camera.
+func (self * POSITModel) Estimate(imagePoints []Point2D32f, focalLength float64, criteria TermCriteria) (rotation Mat3, translation Vec3, err error) {
+  if self.cobject == nil { return rotation, translation, errors.New("opencv: POSIT model was released") }
+  if len(imagePoints) != self.count {
+    return rotation, translation, errors.New("opencv: POSIT needs one image point per model point")
+  }
+  cpoints := make([]C.CvPoint2D32f, len(imagePoints))
+  for i, point := range imagePoints { cpoints[i] = point.cpoint() }
+  var crotation    [9]C.float
+  var ctranslation [3]C.float
+  C.cvPOSIT(self.cobject, &cpoints[0], C.double(focalLength), criteria.ccriteria(),
+            &crotation[0], &ctranslation[0])
+  // Keep the model alive until the native object is no longer in use.
+  runtime.KeepAlive(self)
+  if err = lastError(); err != nil { return rotation, translation, err }
+  for i := 0; i < 9; i++ { rotation[i / 3][i % 3] = float64(crotation[i]) }
+  for i := 0; i < 3; i++ { translation[i] = float64(ctranslation[i]) }
+  return rotation, translation, nil
+}
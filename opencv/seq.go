@@ -0,0 +1,50 @@
+/*
+Helpers to copy data out of OpenCV sequences and memory storages.
+*/
+package opencv
+
+// #include <opencv/cv.h>
+//
+// static CvSeq * new_point_seq(CvMemStorage * storage) {
+//   return cvCreateSeq(CV_SEQ_POLYGON, sizeof(CvContour), sizeof(CvPoint), storage);
+// }
+import "C"
+import "unsafe"
+
+// newStorage creates an OpenCV memory storage with the default block size.
+// Release it with releaseStorage.
+func newStorage() (* C.CvMemStorage) {
+  return C.cvCreateMemStorage(0)
+}
+
+// releaseStorage releases an OpenCV memory storage and everything in it.
+func releaseStorage(cstorage * C.CvMemStorage) {
+  if cstorage != nil {
+    C.cvReleaseMemStorage(&cstorage)
+  }
+}
+
+// seqElem returns a pointer to element i of the sequence.
+func seqElem(cseq * C.CvSeq, i int) unsafe.Pointer {
+  return unsafe.Pointer(C.cvGetSeqElem(cseq, C.int(i)))
+}
+
+// seqPoints copies the CvPoint elements of a sequence to Go.
+func seqPoints(cseq * C.CvSeq) []Point {
+  points := make([]Point, int(cseq.total))
+  for i := range points {
+    points[i] = wrapPoint(*(* C.CvPoint)(seqElem(cseq, i)))
+  }
+  return points
+}
+
+// newPointSeq creates a closed polygon sequence in the storage holding the
+// given points.
+func newPointSeq(cstorage * C.CvMemStorage, points []Point) (* C.CvSeq) {
+  cseq := C.new_point_seq(cstorage)
+  for _, point := range points {
+    cpoint := point.cpoint()
+    C.cvSeqPush(cseq, unsafe.Pointer(&cpoint))
+  }
+  return cseq
+}
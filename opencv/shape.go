@@ -0,0 +1,130 @@
+/*
+Shape analysis of contours and images: area, perimeter and moments.
+*/
+package opencv
+
+// #include <opencv/cv.h>
+import "C"
+import "errors"
+import "math"
+import "unsafe"
+
+// Moments holds the spatial and central moments up to the third order of a
+// contour or image.
+type Moments struct {
+  M00, M10, M01, M20, M11, M02, M30, M21, M12, M03 float64
+  Mu20, Mu11, Mu02, Mu30, Mu21, Mu12, Mu03         float64
+  InvSqrtM00                                       float64
+}
+
+// HuMoments holds the seven Hu invariant moments, which are invariant to
+// translation, scale and rotation.
+type HuMoments [7]float64
+
+// wrapMoments converts OpenCV moments to Moments.
+func wrapMoments(cmoments * C.CvMoments) Moments {
+  return Moments {
+    float64(cmoments.m00), float64(cmoments.m10), float64(cmoments.m01),
+    float64(cmoments.m20), float64(cmoments.m11), float64(cmoments.m02),
+    float64(cmoments.m30), float64(cmoments.m21), float64(cmoments.m12),
+    float64(cmoments.m03),
+    float64(cmoments.mu20), float64(cmoments.mu11), float64(cmoments.mu02),
+    float64(cmoments.mu30), float64(cmoments.mu21), float64(cmoments.mu12),
+    float64(cmoments.mu03),
+    float64(cmoments.inv_sqrt_m00),
+  }
+}
+
+// cmoments converts the moments back to OpenCV moments.
+func (self Moments) cmoments() C.CvMoments {
+  var cmoments C.CvMoments
+  cmoments.m00  = C.double(self.M00)  ; cmoments.m10  = C.double(self.M10)
+  cmoments.m01  = C.double(self.M01)  ; cmoments.m20  = C.double(self.M20)
+  cmoments.m11  = C.double(self.M11)  ; cmoments.m02  = C.double(self.M02)
+  cmoments.m30  = C.double(self.M30)  ; cmoments.m21  = C.double(self.M21)
+  cmoments.m12  = C.double(self.M12)  ; cmoments.m03  = C.double(self.M03)
+  cmoments.mu20 = C.double(self.Mu20) ; cmoments.mu11 = C.double(self.Mu11)
+  cmoments.mu02 = C.double(self.Mu02) ; cmoments.mu30 = C.double(self.Mu30)
+  cmoments.mu21 = C.double(self.Mu21) ; cmoments.mu12 = C.double(self.Mu12)
+  cmoments.mu03 = C.double(self.Mu03)
+  cmoments.inv_sqrt_m00 = C.double(self.InvSqrtM00)
+  return cmoments
+}
+
+// HuMoments calculates the Hu invariant moments from the moments.
+func (self Moments) HuMoments() HuMoments {
+  var chu C.CvHuMoments
+  cmoments := self.cmoments()
+  C.cvGetHuMoments(&cmoments, &chu)
+  return HuMoments{ float64(chu.hu1), float64(chu.hu2), float64(chu.hu3),
+    float64(chu.hu4), float64(chu.hu5), float64(chu.hu6), float64(chu.hu7) }
+}
+
+// cmoments calculates the moments of a point set or raster image.
+func cmoments(carr unsafe.Pointer, binary bool) (Moments, error) {
+  var cmoments C.CvMoments
+  C.cvMoments(carr, &cmoments, cbool(binary))
+  if err := lastError(); err != nil { return Moments{}, err }
+  return wrapMoments(&cmoments), nil
+}
+
+// Moments calculates the raster moments of a single channel image. If binary
+// is true, all non-zero pixels are treated as ones.
+func (self * Image) Moments(binary bool) (Moments, error) {
+  if self == nil { return Moments{}, errors.New("opencv: Moments needs an image") }
+  return cmoments(self.arr(), binary)
+}
+
+// Moments calculates the moments of the polygon formed by the contour.
+func (self * Contour) Moments() (Moments, error) {
+  cmat := newPointMat(self.Points) ; defer releaseCMat(cmat)
+  if cmat == nil { return Moments{}, nil }
+  return cmoments(carr(cmat), false)
+}
+
+// Area returns the signed area of the contour. The sign depends on the
+// orientation of the contour, use math.Abs for the plain area.
+func (self * Contour) Area() (float64, error) {
+  cmat := newPointMat(self.Points) ; defer releaseCMat(cmat)
+  if cmat == nil { return 0, nil }
+  cwhole := C.cvSlice(0, C.CV_WHOLE_SEQ_END_INDEX)
+  carea  := C.cvContourArea(carr(cmat), cwhole)
+  if err := lastError(); err != nil { return 0, err }
+  return float64(carea), nil
+}
+
+// ArcLength returns the perimeter of the contour if closed is true, or the
+// length of the curve through its points otherwise.
+func (self * Contour) ArcLength(closed bool) (float64, error) {
+  cmat := newPointMat(self.Points) ; defer releaseCMat(cmat)
+  if cmat == nil { return 0, nil }
+  cwhole  := C.cvSlice(0, C.CV_WHOLE_SEQ_END_INDEX)
+  clength := C.cvArcLength(carr(cmat), cwhole, cbool(closed))
+  if err := lastError(); err != nil { return 0, err }
+  return float64(clength), nil
+}
+
+// BoundingRect returns the smallest upright rectangle that contains the
+// contour.
+func (self * Contour) BoundingRect() (Rect, error) {
+  cmat := newPointMat(self.Points) ; defer releaseCMat(cmat)
+  if cmat == nil { return Rect{}, nil }
+  crect := C.cvBoundingRect(carr(cmat), 0)
+  if err := lastError(); err != nil { return Rect{}, err }
+  return wrapRect(crect), nil
+}
+
+// MatchShapes compares the contour with another one using their Hu moments.
+// method is one of CONTOURS_MATCH_I1, CONTOURS_MATCH_I2 or
+// CONTOURS_MATCH_I3. The lower the result, the better the shapes match.
+// Empty contours cannot be compared.
+func (self * Contour) MatchShapes(other * Contour, method int) (float64, error) {
+  if other == nil || len(self.Points) == 0 || len(other.Points) == 0 {
+    return math.Inf(1), errors.New("opencv: MatchShapes needs two non-empty contours")
+  }
+  cmat1  := newPointMat(self.Points)  ; defer releaseCMat(cmat1)
+  cmat2  := newPointMat(other.Points) ; defer releaseCMat(cmat2)
+  cmatch := C.cvMatchShapes(carr(cmat1), carr(cmat2), C.int(method), 0)
+  if err := lastError(); err != nil { return math.Inf(1), err }
+  return float64(cmatch), nil
+}
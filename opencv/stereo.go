@@ -0,0 +1,266 @@
+/*
+Stereo calibration, rectification and reprojection to 3D.
+*/
+package opencv
+
+// #include <opencv/cv.h>
+import "C"
+import "bufio"
+import "errors"
+import "fmt"
+import "io"
+import "math"
+import "os"
+
+// Mat34 is a 3x4 matrix in row-major order, such as a projection matrix.
+type Mat34 [3][4]float64
+
+// Mat4 is a 4x4 matrix in row-major order, such as the disparity-to-depth
+// mapping matrix of a rectified stereo pair.
+type Mat4 [4][4]float64
+
+// cmat converts the matrix to a 4x4 CV_64F OpenCV matrix.
+// Release the result with releaseCMat.
+func (self Mat4) cmat() (* C.CvMat) {
+  return newCMat(4, 4, CV_64F, [][]float64{self[0][:], self[1][:], self[2][:], self[3][:]})
+}
+
+// StereoCalibration is the result of StereoCalibrate.
+type StereoCalibration struct {
+  // Left and Right are the intrinsics of the two cameras.
+  Left, Right  CameraIntrinsics
+  // Rotation and Translation transform points from the coordinate system
+  // of the left camera to that of the right camera.
+  Rotation     Mat3
+  Translation  Vec3
+  // Essential and Fundamental are the essential and fundamental matrices
+  // of the camera pair.
+  Essential    Mat3
+  Fundamental  Mat3
+  // ReprojectionError is the root mean square reprojection error in pixels
+  // over both cameras.
+  ReprojectionError float64
+}
+
+// StereoRectification is the result of StereoRectify.
+type StereoRectification struct {
+  // R1 and R2 rotate the left and right camera into the rectified pose.
+  R1, R2 Mat3
+  // P1 and P2 project into the rectified left and right images.
+  P1, P2 Mat34
+  // Q maps a disparity to depth, see ReprojectImageTo3D.
+  Q      Mat4
+}
+
+// StereoCalibrate estimates the transformation between two cameras from
+// several views of a calibration pattern seen by both of them, in images of
+// the given size. left and right hold the initial intrinsics of the cameras,
+// which are fixed with CALIB_FIX_INTRINSIC, typically after calibrating each
+// camera with CalibrateCamera2. flags is a combination of the CALIB_*
+// constants, such as CALIB_SAME_FOCAL_LENGTH.
+func StereoCalibrate(objectPoints [][]Point3D32f, imagePoints1, imagePoints2 [][]Point2D32f, imageSize Size,
+                     left, right CameraIntrinsics, criteria TermCriteria, flags int) (* StereoCalibration, error) {
+  cobject, cimage1, ccounts, err := calibPoints(objectPoints, imagePoints1)
+  if err != nil { return nil, err }
+  defer releaseCMat(cobject)
+  defer releaseCMat(cimage1)
+  defer releaseCMat(ccounts)
+  cobject2, cimage2, ccounts2, err := calibPoints(objectPoints, imagePoints2)
+  if err != nil { return nil, err }
+  releaseCMat(cobject2)
+  releaseCMat(ccounts2)
+  defer releaseCMat(cimage2)
+
+  cmatrix1, cdist1 := left.cmats()
+  defer releaseCMat(cmatrix1)
+  defer releaseCMat(cdist1)
+  cmatrix2, cdist2 := right.cmats()
+  defer releaseCMat(cmatrix2)
+  defer releaseCMat(cdist2)
+  crot   := newCMat(3, 3, CV_64F, nil) ; defer releaseCMat(crot)
+  ctrans := newCMat(3, 1, CV_64F, nil) ; defer releaseCMat(ctrans)
+  cess   := newCMat(3, 3, CV_64F, nil) ; defer releaseCMat(cess)
+  cfund  := newCMat(3, 3, CV_64F, nil) ; defer releaseCMat(cfund)
+
+  C.cvStereoCalibrate(cobject, cimage1, cimage2, ccounts, cmatrix1, cdist1, cmatrix2, cdist2,
+                      imageSize.csize(), crot, ctrans, cess, cfund, criteria.ccriteria(), C.int(flags))
+  if err := lastError(); err != nil { return nil, err }
+
+  result := &StereoCalibration{
+    Left:        wrapIntrinsics(cmatrix1, cdist1),
+    Right:       wrapIntrinsics(cmatrix2, cdist2),
+    Rotation:    wrapMat3(crot),
+    Translation: wrapVec3(ctrans),
+    Essential:   wrapMat3(cess),
+    Fundamental: wrapMat3(cfund),
+  }
+  result.ReprojectionError, err = result.reprojectionError(objectPoints, imagePoints1, imagePoints2)
+  if err != nil { return nil, err }
+  return result, nil
+}
+
+// reprojectionError returns the root mean square distance between the image
+// points of both cameras and the object points projected into them. The pose
+// of the pattern in every view is estimated from the left camera.
+func (self * StereoCalibration) reprojectionError(objectPoints [][]Point3D32f, imagePoints1, imagePoints2 [][]Point2D32f) (float64, error) {
+  sum   := 0.0
+  count := 0
+  accumulate := func(projected, observed []Point2D32f) {
+    for i, point := range projected {
+      dx  := float64(point.X - observed[i].X)
+      dy  := float64(point.Y - observed[i].Y)
+      sum += dx * dx + dy * dy
+      count++
+    }
+  }
+  for view := range objectPoints {
+    rvec, tvec, err := FindExtrinsicCameraParams2(objectPoints[view], imagePoints1[view], self.Left)
+    if err != nil { return 0, err }
+    projected1, _, err := ProjectPoints2(objectPoints[view], rvec, tvec, self.Left, false)
+    if err != nil { return 0, err }
+    // Chain the pose of the pattern with the transformation to the right camera.
+    rmat, err := rvec.RotationMatrix()
+    if err != nil { return 0, err }
+    var rot2 Mat3
+    var trans2 Vec3
+    for i := 0; i < 3; i++ {
+      trans2[i] = self.Translation[i]
+      for j := 0; j < 3; j++ {
+        trans2[i] += self.Rotation[i][j] * tvec[j]
+        for k := 0; k < 3; k++ { rot2[i][j] += self.Rotation[i][k] * rmat[k][j] }
+      }
+    }
+    rvec2, err := rot2.RotationVector()
+    if err != nil { return 0, err }
+    projected2, _, err := ProjectPoints2(objectPoints[view], rvec2, trans2, self.Right, false)
+    if err != nil { return 0, err }
+    accumulate(projected1, imagePoints1[view])
+    accumulate(projected2, imagePoints2[view])
+  }
+  if count == 0 { return 0, nil }
+  return math.Sqrt(sum / float64(count)), nil
+}
+
+// StereoRectify calculates the transformations that make the epipolar lines
+// of a calibrated stereo pair horizontal and aligned, for images of the
+// given size. With CALIB_ZERO_DISPARITY in flags, the principal points of
+// both rectified views are made equal.
+func StereoRectify(calibration * StereoCalibration, imageSize Size, flags int) (* StereoRectification, error) {
+  if calibration == nil { return nil, errors.New("opencv: StereoRectify needs a stereo calibration") }
+  cmatrix1, cdist1 := calibration.Left.cmats()
+  defer releaseCMat(cmatrix1)
+  defer releaseCMat(cdist1)
+  cmatrix2, cdist2 := calibration.Right.cmats()
+  defer releaseCMat(cmatrix2)
+  defer releaseCMat(cdist2)
+  crot   := calibration.Rotation.cmat()    ; defer releaseCMat(crot)
+  ctrans := calibration.Translation.cmat() ; defer releaseCMat(ctrans)
+  cr1    := newCMat(3, 3, CV_64F, nil)     ; defer releaseCMat(cr1)
+  cr2    := newCMat(3, 3, CV_64F, nil)     ; defer releaseCMat(cr2)
+  cp1    := newCMat(3, 4, CV_64F, nil)     ; defer releaseCMat(cp1)
+  cp2    := newCMat(3, 4, CV_64F, nil)     ; defer releaseCMat(cp2)
+  cq     := newCMat(4, 4, CV_64F, nil)     ; defer releaseCMat(cq)
+
+  C.cvStereoRectify(cmatrix1, cmatrix2, cdist1, cdist2, imageSize.csize(), crot, ctrans,
+                    cr1, cr2, cp1, cp2, cq, C.int(flags))
+  if err := lastError(); err != nil { return nil, err }
+
+  result := &StereoRectification{R1: wrapMat3(cr1), R2: wrapMat3(cr2)}
+  for i, row := range cmatData(cp1) { copy(result.P1[i][:], row) }
+  for i, row := range cmatData(cp2) { copy(result.P2[i][:], row) }
+  for i, row := range cmatData(cq)  { copy(result.Q[i][:], row) }
+  return result, nil
+}
+
+// StereoRectifyUncalibrated calculates homographies that rectify the images
+// of an uncalibrated stereo pair of the given size, from corresponding
+// points and their fundamental matrix. Point pairs whose distance to their
+// epipolar lines exceeds threshold pixels are ignored, unless threshold is
+// 0 or less.
+func StereoRectifyUncalibrated(points1, points2 []Point2D32f, fundamental Mat3, imageSize Size,
+                               threshold float64) (h1, h2 Mat3, err error) {
+  if len(points1) == 0 || len(points1) != len(points2) {
+    return h1, h2, errors.New("opencv: StereoRectifyUncalibrated needs pairs of points")
+  }
+  cpoints1 := newPoint2D32fMat(points1)   ; defer releaseCMat(cpoints1)
+  cpoints2 := newPoint2D32fMat(points2)   ; defer releaseCMat(cpoints2)
+  cfund    := fundamental.cmat()          ; defer releaseCMat(cfund)
+  ch1      := newCMat(3, 3, CV_64F, nil)  ; defer releaseCMat(ch1)
+  ch2      := newCMat(3, 3, CV_64F, nil)  ; defer releaseCMat(ch2)
+  cok      := C.cvStereoRectifyUncalibrated(cpoints1, cpoints2, cfund, imageSize.csize(),
+                                            ch1, ch2, C.double(threshold))
+  if err = lastError(); err != nil { return h1, h2, err }
+  if cok == 0 { return h1, h2, errors.New("opencv: could not rectify the stereo pair") }
+  return wrapMat3(ch1), wrapMat3(ch2), nil
+}
+
+// MissingDepth is the Z coordinate ReprojectImageTo3D gives to points whose
+// disparity is missing, when handleMissing is true.
+const MissingDepth = 10000
+
+// ReprojectImageTo3D transforms a single-channel disparity image into a
+// 3-channel 32-bit floating point image of 3D points, using the Q matrix of
+// StereoRectify. If handleMissing is true, points with the minimal disparity
+// get a Z of MissingDepth.
+func ReprojectImageTo3D(disparity, dst * Image, q Mat4, handleMissing bool) error {
+  if disparity == nil || dst == nil { return errors.New("opencv: ReprojectImageTo3D needs a disparity and destination image") }
+  cq := q.cmat() ; defer releaseCMat(cq)
+  C.cvReprojectImageTo3D(disparity.arr(), dst.arr(), cq, cbool(handleMissing))
+  return lastError()
+}
+
+// WritePLY writes the 3D points of an image produced by ReprojectImageTo3D
+// to w as an ASCII PLY point cloud. If colors is not nil, it must be an
+// 8-bit BGR image of the same size, whose pixels color the points. Points
+// that are missing or not finite are left out.
+func WritePLY(w io.Writer, points, colors * Image) error {
+  if points == nil || points.Channels() != 3 {
+    return errors.New("opencv: WritePLY needs a 3-channel image of points")
+  }
+  if colors != nil && (colors.Width() != points.Width() || colors.Height() != points.Height()) {
+    return errors.New("opencv: WritePLY needs a color image of the same size as the points")
+  }
+  type vertex struct {
+    x, y, z float64
+    b, g, r int
+  }
+  var vertices []vertex
+  for y := 0; y < points.Height(); y++ {
+    for x := 0; x < points.Width(); x++ {
+      cpoint := C.cvGet2D(points.arr(), C.int(y), C.int(x))
+      v      := vertex{x: float64(cpoint.val[0]), y: float64(cpoint.val[1]), z: float64(cpoint.val[2])}
+      if math.Abs(v.z - MissingDepth) < 1e-6 || math.IsInf(v.z, 0) || math.IsNaN(v.z) { continue }
+      if colors != nil {
+        ccolor := C.cvGet2D(colors.arr(), C.int(y), C.int(x))
+        v.b, v.g, v.r = int(ccolor.val[0]), int(ccolor.val[1]), int(ccolor.val[2])
+      }
+      vertices = append(vertices, v)
+    }
+  }
+
+  out := bufio.NewWriter(w)
+  fmt.Fprintf(out, "ply\nformat ascii 1.0\nelement vertex %d\n", len(vertices))
+  fmt.Fprintf(out, "property float x\nproperty float y\nproperty float z\n")
+  if colors != nil {
+    fmt.Fprintf(out, "property uchar red\nproperty uchar green\nproperty uchar blue\n")
+  }
+  fmt.Fprintf(out, "end_header\n")
+  for _, v := range vertices {
+    if colors != nil {
+      fmt.Fprintf(out, "%g %g %g %d %d %d\n", v.x, v.y, v.z, v.r, v.g, v.b)
+    } else {
+      fmt.Fprintf(out, "%g %g %g\n", v.x, v.y, v.z)
+    }
+  }
+  return out.Flush()
+}
+
+// SavePLY writes the 3D points of an image produced by ReprojectImageTo3D
+// to the named PLY file, see WritePLY.
+func SavePLY(filename string, points, colors * Image) error {
+  file, err := os.Create(filename)
+  if err != nil { return err }
+  err = WritePLY(file, points, colors)
+  if cerr := file.Close(); err == nil { err = cerr }
+  return err
+}
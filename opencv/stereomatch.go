@@ -0,0 +1,232 @@
+/*
+Stereo correspondence by block matching and graph cuts.
+*/
+package opencv
+
+// #include <opencv/cv.h>
+import "C"
+import "errors"
+
+// StereoBM computes disparities of a rectified stereo pair by block
+// matching. The fields tune the matching and are applied on every call to
+// Compute.
+type StereoBM struct {
+  // PreFilterType is STEREO_BM_NORMALIZED_RESPONSE, the only prefilter.
+  PreFilterType       int
+  // PreFilterSize is the odd window size of the prefilter, 5 to 21.
+  PreFilterSize       int
+  // PreFilterCap clips the prefiltered values, 1 to 63.
+  PreFilterCap        int
+  // SADWindowSize is the odd window size of the matched blocks, 5 to 21.
+  SADWindowSize       int
+  // MinDisparity is the smallest disparity that is searched, usually 0.
+  MinDisparity        int
+  // NumberOfDisparities is the amount of disparities searched, a multiple
+  // of 16.
+  NumberOfDisparities int
+  // TextureThreshold rejects blocks with less texture than this.
+  TextureThreshold    int
+  // UniquenessRatio rejects matches that are not this many percent better
+  // than the second best one.
+  UniquenessRatio     int
+  // SpeckleWindowSize and SpeckleRange filter small speckles of
+  // disparities that differ by more than SpeckleRange from their
+  // neighbours. A SpeckleWindowSize of 0 disables the filter.
+  SpeckleWindowSize   int
+  SpeckleRange        int
+  cstate              * C.CvStereoBMState
+}
+
+// NewStereoBM creates a block matcher with the parameters of the given
+// STEREO_BM_BASIC, STEREO_BM_FISH_EYE or STEREO_BM_NARROW preset, searching
+// numberOfDisparities disparities, or the default amount if 0.
+func NewStereoBM(preset, numberOfDisparities int) (* StereoBM, error) {
+  cstate := C.cvCreateStereoBMState(C.int(preset), C.int(numberOfDisparities))
+  if err := lastError(); err != nil { return nil, err }
+  if cstate == nil { return nil, errors.New("opencv: could not create block matcher") }
+  return &StereoBM{
+    PreFilterType:       int(cstate.preFilterType),
+    PreFilterSize:       int(cstate.preFilterSize),
+    PreFilterCap:        int(cstate.preFilterCap),
+    SADWindowSize:       int(cstate.SADWindowSize),
+    MinDisparity:        int(cstate.minDisparity),
+    NumberOfDisparities: int(cstate.numberOfDisparities),
+    TextureThreshold:    int(cstate.textureThreshold),
+    UniquenessRatio:     int(cstate.uniquenessRatio),
+    SpeckleWindowSize:   int(cstate.speckleWindowSize),
+    SpeckleRange:        int(cstate.speckleRange),
+    cstate:              cstate,
+  }, nil
+}
+
+// Release releases the native state of the block matcher.
+func (self * StereoBM) Release() {
+  if self.cstate != nil {
+    C.cvReleaseStereoBMState(&self.cstate)
+  }
+  self.cstate = nil
+}
+
+// Compute computes the disparities of the 8-bit single channel rectified
+// left and right images. Returns a 16-bit signed image with the disparities
+// of the left image multiplied by 16. Pixels without a disparity are set to
+// (MinDisparity - 1) * 16. Release the result when done.
+func (self * StereoBM) Compute(left, right * Image) (* Image, error) {
+  if self.cstate == nil { return nil, errors.New("opencv: block matcher was released") }
+  if left == nil || right == nil { return nil, errors.New("opencv: StereoBM needs a left and right image") }
+  self.cstate.preFilterType       = C.int(self.PreFilterType)
+  self.cstate.preFilterSize       = C.int(self.PreFilterSize)
+  self.cstate.preFilterCap        = C.int(self.PreFilterCap)
+  self.cstate.SADWindowSize       = C.int(self.SADWindowSize)
+  self.cstate.minDisparity        = C.int(self.MinDisparity)
+  self.cstate.numberOfDisparities = C.int(self.NumberOfDisparities)
+  self.cstate.textureThreshold    = C.int(self.TextureThreshold)
+  self.cstate.uniquenessRatio     = C.int(self.UniquenessRatio)
+  self.cstate.speckleWindowSize   = C.int(self.SpeckleWindowSize)
+  self.cstate.speckleRange        = C.int(self.SpeckleRange)
+
+  disparity := CreateImage(left.Width(), left.Height(), IPL_DEPTH_16S, 1)
+  if disparity == nil { return nil, errors.New("opencv: could not allocate disparity image") }
+  C.cvFindStereoCorrespondenceBM(left.arr(), right.arr(), disparity.arr(), self.cstate)
+  if err := lastError(); err != nil {
+    disparity.Release()
+    return nil, err
+  }
+  return disparity, nil
+}
+
+// DisplayDisparity scales a disparity image computed by Compute to an 8-bit
+// image, mapping the searched disparities to 0 to 255. Release the result
+// when done.
+func (self * StereoBM) DisplayDisparity(disparity * Image) (* Image, error) {
+  scale := 255.0 / float64(self.NumberOfDisparities * 16)
+  return ScaleDisparity(disparity, scale, -float64(self.MinDisparity * 16) * scale)
+}
+
+// StereoGC computes disparities of a rectified stereo pair with graph cuts.
+// It is much slower than block matching, but gives denser and smoother
+// results. The fields tune the matching and are applied on every call to
+// Compute.
+type StereoGC struct {
+  // IThreshold is the threshold of the intensity differences.
+  IThreshold          int
+  // InteractionRadius is the radius of the neighbourhood of a pixel.
+  InteractionRadius   int
+  // K, Lambda, Lambda1, Lambda2 and OcclusionCost weigh the terms of the
+  // energy function. Negative values are calculated from the images.
+  K                   float32
+  Lambda              float32
+  Lambda1             float32
+  Lambda2             float32
+  OcclusionCost       int
+  // MinDisparity is the smallest disparity that is searched, usually 0.
+  MinDisparity        int
+  // NumberOfDisparities is the amount of disparities searched.
+  NumberOfDisparities int
+  // MaxIters is the maximal number of iterations.
+  MaxIters            int
+  cstate              * C.CvStereoGCState
+}
+
+// NewStereoGC creates a graph cut matcher searching numberOfDisparities
+// disparities, with at most maxIters iterations.
+func NewStereoGC(numberOfDisparities, maxIters int) (* StereoGC, error) {
+  cstate := C.cvCreateStereoGCState(C.int(numberOfDisparities), C.int(maxIters))
+  if err := lastError(); err != nil { return nil, err }
+  if cstate == nil { return nil, errors.New("opencv: could not create graph cut matcher") }
+  return &StereoGC{
+    IThreshold:          int(cstate.Ithreshold),
+    InteractionRadius:   int(cstate.interactionRadius),
+    K:                   float32(cstate.K),
+    Lambda:              float32(cstate.lambda),
+    Lambda1:             float32(cstate.lambda1),
+    Lambda2:             float32(cstate.lambda2),
+    OcclusionCost:       int(cstate.occlusionCost),
+    MinDisparity:        int(cstate.minDisparity),
+    NumberOfDisparities: int(cstate.numberOfDisparities),
+    MaxIters:            int(cstate.maxIters),
+    cstate:              cstate,
+  }, nil
+}
+
+// Release releases the native state of the graph cut matcher.
+func (self * StereoGC) Release() {
+  if self.cstate != nil {
+    C.cvReleaseStereoGCState(&self.cstate)
+  }
+  self.cstate = nil
+}
+
+// Compute computes the disparities of the 8-bit single channel rectified
+// left and right images. Returns 16-bit signed images with the disparities
+// of both images. Disparities of the left image are negative. Occluded
+// pixels are set to STEREO_GC_OCCLUDED. Release the results when done.
+func (self * StereoGC) Compute(left, right * Image) (leftDisparity, rightDisparity * Image, err error) {
+  if self.cstate == nil { return nil, nil, errors.New("opencv: graph cut matcher was released") }
+  if left == nil || right == nil { return nil, nil, errors.New("opencv: StereoGC needs a left and right image") }
+  self.cstate.Ithreshold          = C.int(self.IThreshold)
+  self.cstate.interactionRadius   = C.int(self.InteractionRadius)
+  self.cstate.K                   = C.float(self.K)
+  self.cstate.lambda              = C.float(self.Lambda)
+  self.cstate.lambda1             = C.float(self.Lambda1)
+  self.cstate.lambda2             = C.float(self.Lambda2)
+  self.cstate.occlusionCost       = C.int(self.OcclusionCost)
+  self.cstate.minDisparity        = C.int(self.MinDisparity)
+  self.cstate.numberOfDisparities = C.int(self.NumberOfDisparities)
+  self.cstate.maxIters            = C.int(self.MaxIters)
+
+  leftDisparity  = CreateImage(left.Width(), left.Height(), IPL_DEPTH_16S, 1)
+  rightDisparity = CreateImage(right.Width(), right.Height(), IPL_DEPTH_16S, 1)
+  if leftDisparity == nil || rightDisparity == nil {
+    leftDisparity.Release()
+    rightDisparity.Release()
+    return nil, nil, errors.New("opencv: could not allocate disparity images")
+  }
+  C.cvFindStereoCorrespondenceGC(left.arr(), right.arr(), leftDisparity.arr(), rightDisparity.arr(),
+                                 self.cstate, 0)
+  if err = lastError(); err != nil {
+    leftDisparity.Release()
+    rightDisparity.Release()
+    return nil, nil, err
+  }
+  return leftDisparity, rightDisparity, nil
+}
+
+// DisplayDisparity scales a left disparity image computed by Compute to an
+// 8-bit image, mapping the searched disparities to 0 to 255. Occluded
+// pixels become 0. Release the result when done.
+func (self * StereoGC) DisplayDisparity(disparity * Image) (* Image, error) {
+  // Left disparities are negative, so flip the sign while scaling.
+  scale := -255.0 / float64(self.NumberOfDisparities)
+  return ScaleDisparity(disparity, scale, float64(self.MinDisparity) * scale)
+}
+
+// ScaleDisparity converts a disparity image to an 8-bit image by
+// multiplying every disparity by scale and adding shift, saturating the
+// results to 0 to 255. Release the result when done.
+func ScaleDisparity(disparity * Image, scale, shift float64) (* Image, error) {
+  if disparity == nil { return nil, errors.New("opencv: ScaleDisparity needs a disparity image") }
+  display := CreateImage(disparity.Width(), disparity.Height(), IPL_DEPTH_8U, 1)
+  if display == nil { return nil, errors.New("opencv: could not allocate display image") }
+  C.cvConvertScale(disparity.arr(), display.arr(), C.double(scale), C.double(shift))
+  if err := lastError(); err != nil {
+    display.Release()
+    return nil, err
+  }
+  return display, nil
+}
+
+// NormalizeDisparity converts a disparity image to an 8-bit image by
+// stretching its smallest to largest value to 0 to 255, for disparities of
+// any method. Release the result when done.
+func NormalizeDisparity(disparity * Image) (* Image, error) {
+  if disparity == nil { return nil, errors.New("opencv: NormalizeDisparity needs a disparity image") }
+  display := CreateImage(disparity.Width(), disparity.Height(), IPL_DEPTH_8U, 1)
+  if display == nil { return nil, errors.New("opencv: could not allocate display image") }
+  C.cvNormalize(disparity.arr(), display.arr(), 0, 255, MINMAX, nil)
+  if err := lastError(); err != nil {
+    display.Release()
+    return nil, err
+  }
+  return display, nil
+}
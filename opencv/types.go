@@ -0,0 +1,152 @@
+/*
+Basic geometric types shared by the OpenCV wrappers.
+*/
+package opencv
+
+// #include <opencv/cv.h>
+import "C"
+
+// Size is the size of a rectangle or image, in pixels.
+type Size struct {
+  Width  int
+  Height int
+}
+
+// csize converts the size to an OpenCV CvSize.
+func (self Size) csize() C.CvSize {
+  return C.cvSize(C.int(self.Width), C.int(self.Height))
+}
+
+// wrapSize converts an OpenCV CvSize to a Size.
+func wrapSize(csize C.CvSize) Size {
+  return Size{int(csize.width), int(csize.height)}
+}
+
+// cbool converts a Go boolean to a C int flag.
+func cbool(value bool) C.int {
+  if value { return 1 }
+  return 0
+}
+
+// Point is a point with integer coordinates, in pixels.
+type Point struct {
+  X int
+  Y int
+}
+
+// cpoint converts the point to an OpenCV CvPoint.
+func (self Point) cpoint() C.CvPoint {
+  return C.cvPoint(C.int(self.X), C.int(self.Y))
+}
+
+// wrapPoint converts an OpenCV CvPoint to a Point.
+func wrapPoint(cpoint C.CvPoint) Point {
+  return Point{int(cpoint.x), int(cpoint.y)}
+}
+
+// Rect is an upright rectangle, with its top left corner at X, Y.
+type Rect struct {
+  X      int
+  Y      int
+  Width  int
+  Height int
+}
+
+// crect converts the rectangle to an OpenCV CvRect.
+func (self Rect) crect() C.CvRect {
+  return C.cvRect(C.int(self.X), C.int(self.Y), C.int(self.Width), C.int(self.Height))
+}
+
+// wrapRect converts an OpenCV CvRect to a Rect.
+func wrapRect(crect C.CvRect) Rect {
+  return Rect{int(crect.x), int(crect.y), int(crect.width), int(crect.height)}
+}
+
+// Point2D32f is a point with floating point coordinates.
+type Point2D32f struct {
+  X float32
+  Y float32
+}
+
+// cpoint converts the point to an OpenCV CvPoint2D32f.
+func (self Point2D32f) cpoint() C.CvPoint2D32f {
+  return C.cvPoint2D32f(C.double(self.X), C.double(self.Y))
+}
+
+// wrapPoint2D32f converts an OpenCV CvPoint2D32f to a Point2D32f.
+func wrapPoint2D32f(cpoint C.CvPoint2D32f) Point2D32f {
+  return Point2D32f{float32(cpoint.x), float32(cpoint.y)}
+}
+
+// Point3D32f is a point in 3D space with floating point coordinates.
+type Point3D32f struct {
+  X float32
+  Y float32
+  Z float32
+}
+
+// Size2D32f is a size with floating point dimensions.
+type Size2D32f struct {
+  Width  float32
+  Height float32
+}
+
+// Box2D is a rotated rectangle. Angle is the angle in degrees between the
+// horizontal axis and the first side of the box, which has length Width.
+type Box2D struct {
+  Center Point2D32f
+  Size   Size2D32f
+  Angle  float32
+}
+
+// cbox converts the box to an OpenCV CvBox2D.
+func (self Box2D) cbox() C.CvBox2D {
+  var cbox C.CvBox2D
+  cbox.center      = self.Center.cpoint()
+  cbox.size.width  = C.float(self.Size.Width)
+  cbox.size.height = C.float(self.Size.Height)
+  cbox.angle       = C.float(self.Angle)
+  return cbox
+}
+
+// wrapBox2D converts an OpenCV CvBox2D to a Box2D.
+func wrapBox2D(cbox C.CvBox2D) Box2D {
+  size := Size2D32f{float32(cbox.size.width), float32(cbox.size.height)}
+  return Box2D{wrapPoint2D32f(cbox.center), size, float32(cbox.angle)}
+}
+
+// ConnectedComp is a connected component of an image: its area, the mean
+// or fill value of its pixels, and its bounding rectangle.
+type ConnectedComp struct {
+  Area  float64
+  Value [4]float64
+  Rect  Rect
+}
+
+// wrapConnectedComp converts an OpenCV CvConnectedComp to a ConnectedComp.
+func wrapConnectedComp(ccomp C.CvConnectedComp) ConnectedComp {
+  comp := ConnectedComp{Area: float64(ccomp.area), Rect: wrapRect(ccomp.rect)}
+  for i := range comp.Value { comp.Value[i] = float64(ccomp.value.val[i]) }
+  return comp
+}
+
+// TermCriteria tells iterative algorithms when to stop. Type is a
+// combination of TERMCRIT_ITER, to stop after MaxIter iterations, and
+// TERMCRIT_EPS, to stop once the accuracy reaches Epsilon.
+type TermCriteria struct {
+  Type    int
+  MaxIter int
+  Epsilon float64
+}
+
+// ccriteria converts the criteria to an OpenCV CvTermCriteria.
+func (self TermCriteria) ccriteria() C.CvTermCriteria {
+  return C.cvTermCriteria(C.int(self.Type), C.int(self.MaxIter), C.double(self.Epsilon))
+}
+
+// Vec3 is a 3D vector, such as a translation or a rotation vector.
+type Vec3 [3]float64
+
+// Mat3 is a 3x3 matrix in row-major order, such as a rotation or a camera
+// matrix.
+type Mat3 [3][3]float64
@@ -0,0 +1,145 @@
+/*
+Lens undistortion and rectification maps.
+*/
+package opencv
+
+// #include <opencv/cv.h>
+import "C"
+import "errors"
+
+// Undistort2 removes the lens distortion described by intrinsics from the
+// src image into dst. This recalculates the undistortion maps on every
+// call, use an Undistorter for video.
+func Undistort2(src, dst * Image, intrinsics CameraIntrinsics) error {
+  cmatrix, cdist := intrinsics.cmats()
+  defer releaseCMat(cmatrix)
+  defer releaseCMat(cdist)
+  C.cvUndistort2(src.arr(), dst.arr(), cmatrix, cdist)
+  return lastError()
+}
+
+// newMaps allocates a pair of 32-bit floating point maps of the given size.
+func newMaps(size Size) (mapx, mapy * Image, err error) {
+  mapx = CreateImage(size.Width, size.Height, IPL_DEPTH_32F, 1)
+  mapy = CreateImage(size.Width, size.Height, IPL_DEPTH_32F, 1)
+  if mapx == nil || mapy == nil {
+    mapx.Release()
+    mapy.Release()
+    return nil, nil, errors.New("opencv: could not allocate undistortion maps")
+  }
+  return mapx, mapy, nil
+}
+
+// InitUndistortMap calculates the maps that undistort images of the given
+// size for use with Remap. Release the maps when done.
+func InitUndistortMap(intrinsics CameraIntrinsics, size Size) (mapx, mapy * Image, err error) {
+  mapx, mapy, err = newMaps(size)
+  if err != nil { return nil, nil, err }
+  cmatrix, cdist := intrinsics.cmats()
+  defer releaseCMat(cmatrix)
+  defer releaseCMat(cdist)
+  C.cvInitUndistortMap(cmatrix, cdist, mapx.arr(), mapy.arr())
+  if err = lastError(); err != nil {
+    mapx.Release()
+    mapy.Release()
+    return nil, nil, err
+  }
+  return mapx, mapy, nil
+}
+
+// InitUndistortRectifyMap calculates the maps that undistort images of the
+// given size and rectify them with the rotation rectification, projecting
+// them with the camera matrix newMatrix. Release the maps when done.
+func InitUndistortRectifyMap(intrinsics CameraIntrinsics, rectification, newMatrix Mat3, size Size) (mapx, mapy * Image, err error) {
+  mapx, mapy, err = newMaps(size)
+  if err != nil { return nil, nil, err }
+  cmatrix, cdist := intrinsics.cmats()
+  defer releaseCMat(cmatrix)
+  defer releaseCMat(cdist)
+  crect := rectification.cmat() ; defer releaseCMat(crect)
+  cnew  := newMatrix.cmat()     ; defer releaseCMat(cnew)
+  C.cvInitUndistortRectifyMap(cmatrix, cdist, crect, cnew, mapx.arr(), mapy.arr())
+  if err = lastError(); err != nil {
+    mapx.Release()
+    mapy.Release()
+    return nil, nil, err
+  }
+  return mapx, mapy, nil
+}
+
+// Remap transforms the src image into dst using the maps, which give the
+// position in src of every pixel of dst. Pixels that map outside of src are
+// set to 0.
+func Remap(src, dst, mapx, mapy * Image) error {
+  C.cvRemap(src.arr(), dst.arr(), mapx.arr(), mapy.arr(),
+            INTER_LINEAR + WARP_FILL_OUTLIERS, C.cvScalarAll(0))
+  return lastError()
+}
+
+// UndistortPoints calculates the ideal, undistorted positions of points
+// observed by a camera with the given intrinsics. If rectification is not
+// nil, the points are rotated with it. If projection is not nil, the points
+// are projected with it, otherwise normalized coordinates are returned.
+func UndistortPoints(points []Point2D32f, intrinsics CameraIntrinsics, rectification, projection * Mat3) ([]Point2D32f, error) {
+  if len(points) == 0 { return nil, nil }
+  csrc := newPoint2D32fMat(points) ; defer releaseCMat(csrc)
+  cdst := C.cvCreateMat(1, C.int(len(points)), C.CV_32FC2)
+  defer releaseCMat(cdst)
+  cmatrix, cdist := intrinsics.cmats()
+  defer releaseCMat(cmatrix)
+  defer releaseCMat(cdist)
+  var crect, cproj * C.CvMat
+  if rectification != nil { crect = rectification.cmat() ; defer releaseCMat(crect) }
+  if projection != nil    { cproj = projection.cmat()    ; defer releaseCMat(cproj) }
+  C.cvUndistortPoints(csrc, cdst, cmatrix, cdist, crect, cproj)
+  if err := lastError(); err != nil { return nil, err }
+  cdata       := (* [1 << 26]C.CvPoint2D32f)(matData(cdst))
+  undistorted := make([]Point2D32f, len(points))
+  for i := range undistorted { undistorted[i] = wrapPoint2D32f(cdata[i]) }
+  return undistorted, nil
+}
+
+// Undistorter undistorts, and optionally rectifies, a stream of frames of
+// the same size. The undistortion maps are calculated once, so every frame
+// only needs to be remapped.
+type Undistorter struct {
+  size Size
+  mapx * Image
+  mapy * Image
+}
+
+// NewUndistorter creates an undistorter for frames of the given size from a
+// camera with the given intrinsics.
+func NewUndistorter(intrinsics CameraIntrinsics, size Size) (* Undistorter, error) {
+  mapx, mapy, err := InitUndistortMap(intrinsics, size)
+  if err != nil { return nil, err }
+  return &Undistorter{size, mapx, mapy}, nil
+}
+
+// NewRectifyUndistorter creates an undistorter that also rectifies the
+// frames, see InitUndistortRectifyMap.
+func NewRectifyUndistorter(intrinsics CameraIntrinsics, rectification, newMatrix Mat3, size Size) (* Undistorter, error) {
+  mapx, mapy, err := InitUndistortRectifyMap(intrinsics, rectification, newMatrix, size)
+  if err != nil { return nil, err }
+  return &Undistorter{size, mapx, mapy}, nil
+}
+
+// Release releases the maps of the undistorter.
+func (self * Undistorter) Release() {
+  self.mapx.Release()
+  self.mapy.Release()
+  self.mapx = nil
+  self.mapy = nil
+}
+
+// Undistort undistorts the frame src into dst, which must both have the
+// size the undistorter was created for.
+func (self * Undistorter) Undistort(src, dst * Image) error {
+  if self.mapx == nil { return errors.New("opencv: undistorter was released") }
+  if src == nil || dst == nil { return errors.New("opencv: Undistort needs a source and a destination frame") }
+  if src.Width() != self.size.Width || src.Height() != self.size.Height ||
+     dst.Width() != self.size.Width || dst.Height() != self.size.Height {
+    return errors.New("opencv: frame size does not match the undistorter")
+  }
+  return Remap(src, dst, self.mapx, self.mapy)
+}